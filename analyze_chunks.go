@@ -0,0 +1,37 @@
+package main
+
+import (
+	"dupe-file-checker/pkg/chunker"
+	"dupe-file-checker/pkg/reporter"
+	"dupe-file-checker/pkg/scanner"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runAnalyzeChunks estimates how much a chunk-level dedup store would save
+func runAnalyzeChunks(args []string) {
+	fs := flag.NewFlagSet("analyze-chunks", flag.ExitOnError)
+	minSize := fs.Int("min", chunker.DefaultOptions.MinSize, "Minimum chunk size in bytes")
+	avgSize := fs.Int("avg", chunker.DefaultOptions.AvgSize, "Average (target) chunk size in bytes")
+	maxSize := fs.Int("max", chunker.DefaultOptions.MaxSize, "Maximum chunk size in bytes")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: dupe-checker analyze-chunks [--min 2048] [--avg 8192] [--max 65536] <directory>")
+		os.Exit(1)
+	}
+
+	root := fs.Arg(0)
+	files := walk(root, scanner.WalkOptions{})
+	fmt.Printf("Found %d files to chunk\n\n", len(files))
+
+	opts := chunker.Options{MinSize: *minSize, AvgSize: *avgSize, MaxSize: *maxSize}
+	report, err := chunker.Analyze(root, files, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	reporter.PrintChunkReport(report)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/reporter"
+	"dupe-file-checker/pkg/scanner"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// runDedupe scans the given roots and acts on every confirmed duplicate group
+func runDedupe(args []string) {
+	var strategies []string
+	for _, s := range dedupe.Strategies {
+		strategies = append(strategies, string(s))
+	}
+
+	fs := flag.NewFlagSet("dedupe", flag.ExitOnError)
+	keep := fs.String("keep", string(dedupe.KeepOldest), "Which copy to keep: "+strings.Join(strategies, ", "))
+	policyFile := fs.String("policy", "", "JSON file of ordered keep rules; overrides --keep")
+	dryRun := fs.Bool("dry-run", true, "Only report what would be removed; pass --dry-run=false to act")
+	link := fs.String("link", "", "Replace duplicates with links instead of deleting them: hard, reflink, symlink")
+	absolute := fs.Bool("absolute", false, "Use absolute symlink targets instead of relative ones")
+	record := fs.String("record", "dupe-checker-links.jsonl", "File recording every symlink replacement, for revert-links")
+	quarantine := fs.String("quarantine", "", "Move duplicates into this directory, mirroring their paths, instead of deleting them")
+	trash := fs.Bool("trash", false, "Move duplicates to the desktop trash (freedesktop.org) instead of deleting them")
+	emitScript := fs.String("emit-script", "", "Write a reviewable shell script to this file instead of acting (supports --link hard|symlink)")
+	auditLog := fs.String("audit-log", "", "Append a JSON Lines entry for every operation to this file, or \"syslog\"")
+	interactive := fs.Bool("interactive", false, "Review groups one at a time and confirm before acting (implies --dry-run=false)")
+	onlyImages := fs.Bool("only-images", false, "Only consider image files")
+	var protectPatterns []string
+	fs.Func("protect", "Never modify files matching this path or glob, e.g. /data/master/** or *.keep (repeatable)", func(v string) error {
+		protectPatterns = append(protectPatterns, v)
+		return nil
+	})
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: dupe-checker dedupe [--keep oldest | --policy rules.json] [--protect GLOB] [--audit-log FILE|syslog] [--link hard|reflink|symlink | --quarantine DIR | --trash] [--dry-run=false | --interactive | --emit-script out.sh] <directory> [directory...]")
+		os.Exit(1)
+	}
+
+	strategy, err := dedupe.ParseStrategy(*keep)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	policy := dedupe.StrategyPolicy(strategy)
+	if *policyFile != "" {
+		if policy, err = dedupe.LoadPolicy(*policyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	protect, err := dedupe.NewProtector(protectPatterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *emitScript != "" && (*quarantine != "" || *trash) {
+		fmt.Fprintln(os.Stderr, "Error: --emit-script only supports deleting or --link hard|symlink")
+		os.Exit(1)
+	}
+	if *interactive {
+		if *emitScript != "" {
+			fmt.Fprintln(os.Stderr, "Error: --interactive and --emit-script cannot be combined")
+			os.Exit(1)
+		}
+		// The confirmation at the end of the review replaces the dry run
+		*dryRun = false
+	}
+
+	roots := fs.Args()
+	action, err := chooseAction(*link)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if symlink, ok := action.(dedupe.Symlink); ok {
+		symlink.Absolute = *absolute
+		symlink.Roots = roots
+		if !*dryRun && *emitScript == "" {
+			recorder, err := dedupe.CreateRecorder(*record)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer recorder.Close()
+			symlink.Record = recorder
+		}
+		action = symlink
+	}
+	// Never treat files dedupe already moved aside, or trashed files, as
+	// copies to keep, whatever the action
+	t, trashErr := dedupe.NewTrash()
+	exclude := t.Dirs(roots)
+	if *quarantine != "" {
+		exclude = append(exclude, *quarantine)
+		if *link != "" {
+			fmt.Fprintln(os.Stderr, "Error: --quarantine and --link cannot be combined")
+			os.Exit(1)
+		}
+		q := &dedupe.Quarantine{Dir: *quarantine}
+		if !*dryRun {
+			if q, err = dedupe.OpenQuarantine(*quarantine); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			defer q.Close()
+		}
+		action = q
+	}
+	if *trash {
+		if *link != "" || *quarantine != "" {
+			fmt.Fprintln(os.Stderr, "Error: --trash cannot be combined with --link or --quarantine")
+			os.Exit(1)
+		}
+		if trashErr != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", trashErr)
+			os.Exit(1)
+		}
+		action = t
+	}
+
+	opts := dedupe.Options{DryRun: *dryRun, Protect: protect}
+	if !*dryRun && *emitScript == "" {
+		opts.Audit = openAuditLog(*auditLog)
+		defer opts.Audit.Close()
+	}
+
+	files, err := scanner.WalkRoots(roots, scanner.WalkOptions{OnlyImages: *onlyImages, Exclude: exclude})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	duplicates := scanner.New().ScanFiles(files, *onlyImages)
+	plan := dedupe.NewPlan(duplicates, policy, roots, protect)
+
+	if *emitScript != "" {
+		opts := dedupe.ScriptOptions{Link: *link, Absolute: *absolute}
+		if err := dedupe.CreateScript(*emitScript, plan, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Wrote %d groups to %s; review it, then run: sh %s\n", len(plan.Groups), *emitScript, *emitScript)
+		return
+	}
+
+	if *interactive {
+		remove, linker := action, dedupe.Action(dedupe.HardLink{})
+		if *link != "" {
+			remove, linker = dedupe.Delete{}, action
+		}
+		runInteractive(plan, remove, linker, opts)
+		return
+	}
+
+	summary := dedupe.Execute(plan, action, opts)
+	reporter.PrintDedupeSummary(summary)
+}
+
+// chooseAction maps the dedupe flags to the action applied to each duplicate
+func chooseAction(link string) (dedupe.Action, error) {
+	switch link {
+	case "":
+		return dedupe.Delete{}, nil
+	case "hard":
+		return dedupe.HardLink{}, nil
+	case "reflink":
+		return dedupe.Reflink{}, nil
+	case "symlink":
+		return dedupe.Symlink{}, nil
+	default:
+		return nil, fmt.Errorf("unknown link type %q", link)
+	}
+}
+
+// openAuditLog opens the --audit-log target, or returns nil when none was
+// given. It exits on failure, before anything is touched.
+func openAuditLog(target string) *dedupe.AuditLog {
+	if target == "" {
+		return nil
+	}
+	audit, err := dedupe.OpenAuditLog(target)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return audit
+}
+
+// runRevertLinks turns the symlinks listed in a dedupe record back into copies
+func runRevertLinks(args []string) {
+	fs := flag.NewFlagSet("revert-links", flag.ExitOnError)
+	auditLog := fs.String("audit-log", "", "Append a JSON Lines entry for every reverted link to this file, or \"syslog\"")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: dupe-checker revert-links [--audit-log FILE|syslog] <record.jsonl>")
+		os.Exit(1)
+	}
+
+	audit := openAuditLog(*auditLog)
+	defer audit.Close()
+	summary, err := dedupe.RevertLinks(fs.Arg(0), audit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	reporter.PrintDedupeSummary(summary)
+}
+
+// runRestore moves everything in a quarantine directory back where it came from
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	auditLog := fs.String("audit-log", "", "Append a JSON Lines entry for every restored file to this file, or \"syslog\"")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: dupe-checker restore [--audit-log FILE|syslog] <quarantine-dir>")
+		os.Exit(1)
+	}
+
+	audit := openAuditLog(*auditLog)
+	defer audit.Close()
+	summary, err := dedupe.RestoreQuarantine(fs.Arg(0), audit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	reporter.PrintDedupeSummary(summary)
+}
+
+// runPurge permanently deletes quarantined files past a certain age
+func runPurge(args []string) {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	olderThan := fs.String("older-than", "30d", "Only purge files quarantined at least this long ago (e.g. 30d, 2w, 12h)")
+	auditLog := fs.String("audit-log", "", "Append a JSON Lines entry for every purged file to this file, or \"syslog\"")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Println("Usage: dupe-checker purge [--older-than 30d] [--audit-log FILE|syslog] <quarantine-dir>")
+		os.Exit(1)
+	}
+
+	age, err := dedupe.ParseAge(*olderThan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	audit := openAuditLog(*auditLog)
+	defer audit.Close()
+	summary, err := dedupe.PurgeQuarantine(fs.Arg(0), age, time.Now(), audit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	reporter.PrintDedupeSummary(summary)
+}
@@ -2,4 +2,4 @@ module dupe-file-checker
 
 go 1.25.1
 
-require github.com/cespare/xxhash/v2 v2.3.0 // indirect
+require github.com/cespare/xxhash/v2 v2.3.0
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/reporter"
+	"dupe-file-checker/pkg/review"
+	"dupe-file-checker/pkg/terminal"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+// runInteractive reviews the plan group by group on the terminal and applies
+// the queued decisions once the user confirms
+func runInteractive(plan dedupe.Plan, remove, link dedupe.Action, opts dedupe.Options) {
+	if len(plan.Groups) == 0 {
+		fmt.Println("No duplicates to review")
+		return
+	}
+
+	queue := reviewPlan(plan)
+	if len(queue.Delete.Groups) > 0 {
+		reporter.PrintDedupeSummary(dedupe.Execute(queue.Delete, remove, opts))
+	}
+	if len(queue.Link.Groups) > 0 {
+		reporter.PrintDedupeSummary(dedupe.Execute(queue.Link, link, opts))
+	}
+}
+
+// reviewPlan runs the review with the terminal in raw mode, restoring it
+// before returning so the actions themselves run with a normal terminal
+func reviewPlan(plan dedupe.Plan) review.Queue {
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		restore, err := terminal.Raw(fd)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer restore()
+
+		// Put the terminal back if the review is interrupted
+		interrupt := make(chan os.Signal, 1)
+		signal.Notify(interrupt, os.Interrupt)
+		defer signal.Stop(interrupt)
+		go func() {
+			if _, ok := <-interrupt; ok {
+				restore()
+				fmt.Println("\nInterrupted, nothing was changed")
+				os.Exit(130)
+			}
+		}()
+	} else {
+		fmt.Println("(input is not a terminal; type each key followed by Enter)")
+	}
+
+	queue, err := review.New(os.Stdin, os.Stdout).Run(plan)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return queue
+}
@@ -1,30 +1,189 @@
 package main
 
 import (
+	"dupe-file-checker/pkg/archive"
+	"dupe-file-checker/pkg/audio"
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/imagehash"
+	"dupe-file-checker/pkg/minhash"
+	"dupe-file-checker/pkg/overlap"
 	"dupe-file-checker/pkg/reporter"
 	"dupe-file-checker/pkg/scanner"
+	"dupe-file-checker/pkg/textnorm"
 	"flag"
 	"fmt"
 	"os"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "analyze-chunks":
+			runAnalyzeChunks(os.Args[2:])
+			return
+		case "dedupe":
+			runDedupe(os.Args[2:])
+			return
+		case "revert-links":
+			runRevertLinks(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "purge":
+			runPurge(os.Args[2:])
+			return
+		}
+	}
+
 	onlyImages := flag.Bool("only-images", false, "Only check image files (jpg, jpeg, png, gif, heic, heif, webp, bmp)")
+	archives := flag.Bool("archives", false, "Also compare files stored inside zip, tar and tar.gz archives")
+	overlapThreshold := flag.Float64("overlap", 0, "Also report directory pairs whose content similarity reaches this ratio (0-1)")
+	mode := flag.String("mode", "exact", "Comparison mode: exact, similar-images, jpeg-data, pixels, audio, text, archive-contents, similar-text, partial")
+	collapseWhitespace := flag.Bool("collapse-whitespace", false, "Treat runs of whitespace as a single space in text mode")
+	foldCase := flag.Bool("fold-case", false, "Ignore letter case in text mode")
+	ignoreMemberMTimes := flag.Bool("ignore-member-mtimes", true, "Ignore member modification times in archive-contents mode")
+	ignoreMemberOrder := flag.Bool("ignore-member-order", true, "Ignore member order in archive-contents mode")
+	jaccard := flag.Float64("jaccard", 0.8, "Minimum estimated Jaccard similarity (0-1) for similar-text mode")
+	maxDistance := flag.Int("max-distance", 10, "Maximum perceptual hash distance (0-64) for similar-images mode")
+	format := flag.String("format", "text", "Output format: text, json or csv")
+	header := flag.Bool("header", true, "Write a header row in csv format")
+	keep := flag.String("keep", string(dedupe.KeepOldest), "Copy to mark as the suggested keeper in csv format (see dedupe --keep)")
 	flag.Parse()
 
 	if flag.NArg() < 1 {
-		fmt.Println("Usage: dupe-checker [--only-images] <directory>")
+		fmt.Println("Usage: dupe-checker [--only-images] [--archives] [--overlap 0.8] [--format text|json|csv] [--mode exact|similar-images|jpeg-data|pixels|audio|text|archive-contents|similar-text|partial] <directory>")
+		fmt.Println("       dupe-checker dedupe [--keep oldest | --policy rules.json] [--protect GLOB] [--audit-log FILE|syslog] [--link hard|reflink|symlink | --quarantine DIR | --trash] [--dry-run=false | --interactive | --emit-script out.sh] <directory> [directory...]")
+		fmt.Println("       dupe-checker revert-links [--audit-log FILE|syslog] <record.jsonl>")
+		fmt.Println("       dupe-checker restore [--audit-log FILE|syslog] <quarantine-dir>")
+		fmt.Println("       dupe-checker purge [--older-than 30d] [--audit-log FILE|syslog] <quarantine-dir>")
+		fmt.Println("       dupe-checker analyze-chunks [--min 2048] [--avg 8192] [--max 65536] <directory>")
 		os.Exit(1)
 	}
 
 	root := flag.Arg(0)
 
-	s := scanner.New()
-	duplicates, err := s.Scan(root, *onlyImages)
+	strategy, err := dedupe.ParseStrategy(*keep)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	reporter.PrintDuplicates(duplicates)
+	// Machine-readable output owns stdout; progress goes to stderr
+	status := os.Stdout
+	switch *format {
+	case "text":
+	case "json", "csv":
+		status = os.Stderr
+		if *mode == "similar-images" || *mode == "similar-text" {
+			fmt.Fprintf(os.Stderr, "Error: --format %s is not supported in %s mode\n", *format, *mode)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", *format)
+		os.Exit(1)
+	}
+
+	// report prints duplicate groups in the chosen format
+	report := func(files []scanner.FileInfo, groups []scanner.DuplicateGroup, pairs []overlap.Pair) {
+		switch *format {
+		case "text":
+			reporter.PrintMatches(groups)
+			return
+		case "csv":
+			if err := reporter.WriteCSV(os.Stdout, groups, dedupe.StrategyPolicy(strategy), []string{root}, *header); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		options := make(map[string]string)
+		flag.VisitAll(func(f *flag.Flag) {
+			options[f.Name] = f.Value.String()
+		})
+		doc := reporter.NewJSONReport(options, []string{root}, len(files), groups, pairs)
+		if err := reporter.WriteJSON(os.Stdout, doc); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	switch *mode {
+	case "exact":
+		files := walk(root, scanner.WalkOptions{OnlyImages: *onlyImages, Archives: *archives})
+
+		s := scanner.New()
+		s.Progress = status
+		duplicates := s.ScanFiles(files, *onlyImages)
+
+		var pairs []overlap.Pair
+		if *overlapThreshold > 0 {
+			pairs = overlap.Analyze(files, duplicates, *overlapThreshold)
+		}
+
+		if *format != "text" {
+			report(files, duplicates, pairs)
+			break
+		}
+		reporter.PrintDuplicates(duplicates)
+		if *overlapThreshold > 0 {
+			reporter.PrintOverlaps(pairs)
+		}
+	case "similar-images":
+		files := walk(root, scanner.WalkOptions{OnlyImages: true})
+		fmt.Fprintf(status, "Found %d image files to compare\n\n", len(files))
+
+		reporter.PrintImageClusters(imagehash.FindClusters(files, *maxDistance))
+	case "jpeg-data":
+		files := walk(root, scanner.WalkOptions{OnlyImages: true})
+		fmt.Fprintf(status, "Found %d image files to compare\n\n", len(files))
+
+		report(files, scanner.New().FindSameJPEGData(files), nil)
+	case "pixels":
+		files := walk(root, scanner.WalkOptions{OnlyImages: true})
+		fmt.Fprintf(status, "Found %d image files to compare\n\n", len(files))
+
+		report(files, imagehash.FindPixelMatches(scanner.New(), files), nil)
+	case "audio":
+		files := walk(root, scanner.WalkOptions{})
+		fmt.Fprintf(status, "Found %d files to compare\n\n", len(files))
+
+		report(files, audio.FindMatches(scanner.New(), files), nil)
+	case "text":
+		files := walk(root, scanner.WalkOptions{})
+		fmt.Fprintf(status, "Found %d files to compare\n\n", len(files))
+
+		opts := textnorm.Options{CollapseWhitespace: *collapseWhitespace, FoldCase: *foldCase}
+		report(files, textnorm.FindEquivalent(scanner.New(), files, opts), nil)
+	case "archive-contents":
+		files := walk(root, scanner.WalkOptions{})
+		fmt.Fprintf(status, "Found %d files to compare\n\n", len(files))
+
+		opts := archive.CompareOptions{IgnoreModTimes: *ignoreMemberMTimes, IgnoreOrder: *ignoreMemberOrder}
+		report(files, scanner.New().FindEquivalentArchives(files, opts), nil)
+	case "similar-text":
+		files := walk(root, scanner.WalkOptions{})
+		fmt.Fprintf(status, "Found %d files to compare\n\n", len(files))
+
+		reporter.PrintTextClusters(minhash.FindClusters(files, *jaccard))
+	case "partial":
+		files := walk(root, scanner.WalkOptions{OnlyImages: *onlyImages})
+		fmt.Fprintf(status, "Found %d files to compare\n\n", len(files))
+
+		report(files, scanner.New().FindPartialCopies(files), nil)
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown mode %q\n", *mode)
+		os.Exit(1)
+	}
+}
+
+// walk lists the files under root or exits on failure
+func walk(root string, opts scanner.WalkOptions) []scanner.FileInfo {
+	files, err := scanner.WalkWithOptions(root, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return files
 }
@@ -0,0 +1,340 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Separator joins an archive path and a member name into a virtual path,
+// e.g. backup.zip!/docs/a.pdf
+const Separator = "!/"
+
+// Member is a regular file stored inside an archive
+type Member struct {
+	Name    string
+	Size    int64
+	ModTime int64
+}
+
+// IsArchive reports whether path names a zip, tar or gzipped tar file
+func IsArchive(p string) bool {
+	lower := strings.ToLower(p)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar") ||
+		strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+func isZip(p string) bool {
+	return strings.HasSuffix(strings.ToLower(p), ".zip")
+}
+
+func isGzip(p string) bool {
+	lower := strings.ToLower(p)
+	return strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// VirtualPath builds the path under which a member is reported
+func VirtualPath(archivePath, member string) string {
+	return archivePath + Separator + member
+}
+
+// Split breaks a virtual path into the archive path and the member name
+func Split(virtual string) (string, string, bool) {
+	i := strings.Index(virtual, Separator)
+	if i < 0 || !IsArchive(virtual[:i]) {
+		return "", "", false
+	}
+	return virtual[:i], virtual[i+len(Separator):], true
+}
+
+// List returns the regular files stored in an archive
+func List(archivePath string) ([]Member, error) {
+	if isZip(archivePath) {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		var members []Member
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			members = append(members, Member{
+				Name:    cleanName(f.Name),
+				Size:    int64(f.UncompressedSize64),
+				ModTime: f.Modified.Unix(),
+			})
+		}
+		return members, nil
+	}
+
+	var members []Member
+	err := walkTar(archivePath, func(hdr *tar.Header, _ io.Reader) (bool, error) {
+		members = append(members, Member{
+			Name:    cleanName(hdr.Name),
+			Size:    hdr.Size,
+			ModTime: hdr.ModTime.Unix(),
+		})
+		return false, nil
+	})
+	return members, err
+}
+
+// Open opens a file by its plain or virtual path
+func Open(p string) (io.ReadCloser, error) {
+	archivePath, member, ok := Split(p)
+	if !ok {
+		return os.Open(p)
+	}
+
+	if isZip(archivePath) {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if cleanName(f.Name) != member {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				zr.Close()
+				return nil, err
+			}
+			return &memberReader{Reader: rc, closers: []io.Closer{rc, zr}}, nil
+		}
+		zr.Close()
+		return nil, fmt.Errorf("%s: member not found", p)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	closers := []io.Closer{f}
+
+	var r io.Reader = f
+	if isGzip(archivePath) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		closers = append([]io.Closer{gz}, closers...)
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			closeAll(closers)
+			if err == io.EOF {
+				return nil, fmt.Errorf("%s: member not found", p)
+			}
+			return nil, err
+		}
+		if hdr.Typeflag == tar.TypeReg && cleanName(hdr.Name) == member {
+			return &memberReader{Reader: tr, closers: closers}, nil
+		}
+	}
+}
+
+// ReadMembers calls fn with the content of each wanted member, reading the
+// archive only once. Tar members can only be reached by reading everything
+// stored before them, so opening many of them one by one with Open is
+// quadratic on large (especially gzipped) archives.
+func ReadMembers(archivePath string, wanted map[string]bool, fn func(name string, r io.Reader) error) error {
+	if isZip(archivePath) {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			name := cleanName(f.Name)
+			if !f.Mode().IsRegular() || !wanted[name] {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = fn(name, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	left := len(wanted)
+	return walkTar(archivePath, func(hdr *tar.Header, r io.Reader) (bool, error) {
+		name := cleanName(hdr.Name)
+		if !wanted[name] {
+			return false, nil
+		}
+		left--
+		return left == 0, fn(name, r)
+	})
+}
+
+// walkTar calls fn for every regular file of a (possibly gzipped) tar until
+// fn returns true or an error
+func walkTar(archivePath string, fn func(*tar.Header, io.Reader) (bool, error)) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if isGzip(archivePath) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		done, err := fn(hdr, tr)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// cleanName normalises member names so "./a/b" and "a/b" are the same member
+func cleanName(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// memberReader reads one archive member and closes everything it was opened through
+type memberReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (m *memberReader) Close() error {
+	return closeAll(m.closers)
+}
+
+func closeAll(closers []io.Closer) error {
+	var first error
+	for _, c := range closers {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// CompareOptions controls which member properties archive fingerprints cover
+type CompareOptions struct {
+	IgnoreModTimes bool
+	IgnoreOrder    bool
+}
+
+// entry is a member together with the hash of its content
+type entry struct {
+	Member
+	hash uint64
+}
+
+// Fingerprint hashes an archive's member listing and member contents rather
+// than its container bytes, so archives built from the same inputs at
+// different times or with different compression fingerprint the same
+func Fingerprint(archivePath string, opts CompareOptions) (uint64, error) {
+	entries, err := hashMembers(archivePath)
+	if err != nil {
+		return 0, err
+	}
+
+	if opts.IgnoreOrder {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name < entries[j].Name
+		})
+	}
+
+	h := xxhash.New()
+	for _, e := range entries {
+		mtime := e.ModTime
+		if opts.IgnoreModTimes {
+			mtime = 0
+		}
+		fmt.Fprintf(h, "%q %d %d %x\n", e.Name, e.Size, mtime, e.hash)
+	}
+	return h.Sum64(), nil
+}
+
+// hashMembers reads every regular member of an archive and hashes its content
+func hashMembers(archivePath string) ([]entry, error) {
+	if isZip(archivePath) {
+		zr, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+
+		var entries []entry
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			h := xxhash.New()
+			_, err = io.Copy(h, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry{
+				Member: Member{Name: cleanName(f.Name), Size: int64(f.UncompressedSize64), ModTime: f.Modified.Unix()},
+				hash:   h.Sum64(),
+			})
+		}
+		return entries, nil
+	}
+
+	var entries []entry
+	err := walkTar(archivePath, func(hdr *tar.Header, r io.Reader) (bool, error) {
+		h := xxhash.New()
+		if _, err := io.Copy(h, r); err != nil {
+			return false, err
+		}
+		entries = append(entries, entry{
+			Member: Member{Name: cleanName(hdr.Name), Size: hdr.Size, ModTime: hdr.ModTime.Unix()},
+			hash:   h.Sum64(),
+		})
+		return false, nil
+	})
+	return entries, err
+}
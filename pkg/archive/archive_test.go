@@ -0,0 +1,168 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testMembers = map[string]string{
+	"docs/a.txt": "alpha content",
+	"b.txt":      "bravo",
+}
+
+func writeZip(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range testMembers {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Create("empty-dir/")
+	zw.Close()
+}
+
+func writeTarGz(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	tw.WriteHeader(&tar.Header{Name: "./docs/", Typeflag: tar.TypeDir, Mode: 0755})
+	for name, content := range testMembers {
+		tw.WriteHeader(&tar.Header{
+			Name:     "./" + name,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			ModTime:  time.Unix(1700000000, 0),
+			Typeflag: tar.TypeReg,
+		})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	gz.Close()
+}
+
+func TestSplit(t *testing.T) {
+	a, m, ok := Split("/data/backup.zip!/docs/a.pdf")
+	if !ok || a != "/data/backup.zip" || m != "docs/a.pdf" {
+		t.Errorf("Split = %q, %q, %v", a, m, ok)
+	}
+	if _, _, ok := Split("/data/report!/final.txt"); ok {
+		t.Error("Expected non-archive path not to split")
+	}
+}
+
+func TestListAndOpen(t *testing.T) {
+	tmpDir := t.TempDir()
+	archives := []string{filepath.Join(tmpDir, "backup.zip"), filepath.Join(tmpDir, "backup.tar.gz")}
+	writeZip(t, archives[0])
+	writeTarGz(t, archives[1])
+
+	for _, path := range archives {
+		members, err := List(path)
+		if err != nil {
+			t.Fatalf("List(%s) failed: %v", path, err)
+		}
+		if len(members) != len(testMembers) {
+			t.Errorf("List(%s) returned %d members; want %d", path, len(members), len(testMembers))
+		}
+
+		for _, m := range members {
+			want, ok := testMembers[m.Name]
+			if !ok {
+				t.Errorf("Unexpected member %q in %s", m.Name, path)
+				continue
+			}
+			if m.Size != int64(len(want)) {
+				t.Errorf("%s size = %d; want %d", m.Name, m.Size, len(want))
+			}
+
+			rc, err := Open(VirtualPath(path, m.Name))
+			if err != nil {
+				t.Fatalf("Open failed: %v", err)
+			}
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(data) != want {
+				t.Errorf("%s content = %q; want %q", m.Name, data, want)
+			}
+		}
+
+		if _, err := Open(VirtualPath(path, "missing.txt")); err == nil {
+			t.Errorf("Expected error opening missing member of %s", path)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	tmpDir := t.TempDir()
+	zipPath := filepath.Join(tmpDir, "backup.zip")
+	tarPath := filepath.Join(tmpDir, "backup.tar.gz")
+	writeZip(t, zipPath)
+	writeTarGz(t, tarPath)
+
+	loose := CompareOptions{IgnoreModTimes: true, IgnoreOrder: true}
+	a, err := Fingerprint(zipPath, loose)
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	b, err := Fingerprint(tarPath, loose)
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if a != b {
+		t.Error("Expected archives with equal members to fingerprint the same")
+	}
+
+	strict := CompareOptions{IgnoreOrder: true}
+	a, _ = Fingerprint(zipPath, strict)
+	b, _ = Fingerprint(tarPath, strict)
+	if a == b {
+		t.Error("Expected member mtimes to matter when not ignored")
+	}
+}
+
+func TestReadMembers(t *testing.T) {
+	tmpDir := t.TempDir()
+	archives := []string{filepath.Join(tmpDir, "backup.zip"), filepath.Join(tmpDir, "backup.tar.gz")}
+	writeZip(t, archives[0])
+	writeTarGz(t, archives[1])
+
+	for _, path := range archives {
+		wanted := make(map[string]bool)
+		for name := range testMembers {
+			wanted[name] = true
+		}
+
+		got := make(map[string]string)
+		err := ReadMembers(path, wanted, func(name string, r io.Reader) error {
+			data, err := io.ReadAll(r)
+			got[name] = string(data)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("ReadMembers(%s) failed: %v", path, err)
+		}
+		for name, want := range testMembers {
+			if got[name] != want {
+				t.Errorf("%s: %s content = %q; want %q", path, name, got[name], want)
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Info describes the audio payload of a file and the tags wrapped around it
+type Info struct {
+	// Hash covers only the audio frames
+	Hash uint64
+
+	// Start and End delimit the audio payload within the file
+	Start int64
+	End   int64
+
+	// Tags maps tag field names (ID3v2 frame IDs, ID3v1 fields, Vorbis
+	// comment keys) to their values
+	Tags map[string]string
+}
+
+var errUnsupported = errors.New("unsupported audio format")
+
+// IsAudio reports whether path has an extension the audio mode understands
+func IsAudio(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".mp3" || ext == ".flac"
+}
+
+// Compute locates the audio payload of an MP3 or FLAC file by parsing its
+// tag containers and hashes the payload alone
+func Compute(path string) (Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{End: stat.Size(), Tags: make(map[string]string)}
+
+	// Both formats may be preceded by one or more ID3v2 tags
+	for {
+		n, err := readID3v2(f, stat.Size(), info.Start, info.Tags)
+		if err != nil {
+			return Info{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if n == 0 {
+			break
+		}
+		info.Start += n
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp3":
+		err = trimMP3Trailer(f, &info)
+	case ".flac":
+		err = skipFLACMetadata(f, &info)
+	default:
+		err = errUnsupported
+	}
+	if err != nil {
+		return Info{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if info.End < info.Start {
+		return Info{}, fmt.Errorf("%s: no audio payload", path)
+	}
+
+	h := xxhash.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, info.Start, info.End-info.Start)); err != nil {
+		return Info{}, err
+	}
+	info.Hash = h.Sum64()
+
+	return info, nil
+}
+
+// readID3v2 parses an ID3v2 tag at offset, if any, and returns its total size.
+// fileSize bounds the tag so a corrupt size field cannot force a huge read.
+func readID3v2(r io.ReaderAt, fileSize, offset int64, tags map[string]string) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := r.ReadAt(header, offset); err != nil {
+		return 0, nil
+	}
+	if string(header[:3]) != "ID3" {
+		return 0, nil
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := int64(syncsafe(header[6:10]))
+	total := 10 + size
+	if flags&0x10 != 0 {
+		total += 10 // footer
+	}
+	if offset+10+size > fileSize {
+		return 0, fmt.Errorf("truncated ID3v2 tag")
+	}
+
+	body := make([]byte, size)
+	if _, err := r.ReadAt(body, offset+10); err != nil {
+		return 0, fmt.Errorf("truncated ID3v2 tag")
+	}
+	parseID3v2Frames(body, version, tags)
+
+	return total, nil
+}
+
+// parseID3v2Frames records the text frames of an ID3v2.2, 2.3 or 2.4 tag
+func parseID3v2Frames(body []byte, version byte, tags map[string]string) {
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+
+	for len(body) >= headerLen && body[0] != 0 {
+		id := string(body[:idLen])
+
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 4:
+			size = int(syncsafe(body[4:8]))
+		default:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if size < 0 || headerLen+size > len(body) {
+			return
+		}
+
+		data := body[headerLen : headerLen+size]
+		if strings.HasPrefix(id, "T") && len(data) > 0 {
+			tags[id] = decodeID3Text(data[0], data[1:])
+		} else {
+			// Binary frames such as artwork are compared by content
+			tags[id] = fmt.Sprintf("<%d bytes, %x>", len(data), xxhash.Sum64(data))
+		}
+		body = body[headerLen+size:]
+	}
+}
+
+// decodeID3Text converts an ID3 text payload to UTF-8
+func decodeID3Text(encoding byte, data []byte) string {
+	switch encoding {
+	case 1, 2:
+		order := binary.ByteOrder(binary.BigEndian)
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE {
+			order = binary.LittleEndian
+			data = data[2:]
+		} else if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF {
+			data = data[2:]
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			units = append(units, order.Uint16(data[i:]))
+		}
+		return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+	case 3:
+		return strings.TrimRight(string(data), "\x00")
+	default:
+		return latin1(data)
+	}
+}
+
+// trimMP3Trailer moves info.End before any APEv2 and ID3v1 tags at the end
+func trimMP3Trailer(r io.ReaderAt, info *Info) error {
+	if info.End-info.Start >= 128 {
+		trailer := make([]byte, 128)
+		if _, err := r.ReadAt(trailer, info.End-128); err != nil {
+			return err
+		}
+		if string(trailer[:3]) == "TAG" {
+			fields := []struct {
+				name       string
+				start, end int
+			}{
+				{"ID3v1 title", 3, 33},
+				{"ID3v1 artist", 33, 63},
+				{"ID3v1 album", 63, 93},
+				{"ID3v1 year", 93, 97},
+				{"ID3v1 comment", 97, 127},
+			}
+			for _, field := range fields {
+				info.Tags[field.name] = strings.TrimRight(latin1(trailer[field.start:field.end]), "\x00 ")
+			}
+			info.Tags["ID3v1 genre"] = fmt.Sprint(trailer[127])
+			info.End -= 128
+		}
+	}
+
+	if info.End-info.Start >= 32 {
+		footer := make([]byte, 32)
+		if _, err := r.ReadAt(footer, info.End-32); err != nil {
+			return err
+		}
+		if string(footer[:8]) == "APETAGEX" {
+			size := int64(binary.LittleEndian.Uint32(footer[12:16]))
+			flags := binary.LittleEndian.Uint32(footer[20:24])
+			if flags&0x80000000 != 0 {
+				size += 32 // header
+			}
+			info.Tags["APEv2"] = fmt.Sprintf("<%d bytes>", size)
+			info.End -= size
+		}
+	}
+	return nil
+}
+
+// skipFLACMetadata moves info.Start past the FLAC metadata blocks, recording
+// Vorbis comments along the way
+func skipFLACMetadata(r io.ReaderAt, info *Info) error {
+	magic := make([]byte, 4)
+	if _, err := r.ReadAt(magic, info.Start); err != nil || string(magic) != "fLaC" {
+		return errUnsupported
+	}
+	info.Start += 4
+
+	for {
+		header := make([]byte, 4)
+		if _, err := r.ReadAt(header, info.Start); err != nil {
+			return fmt.Errorf("truncated FLAC metadata")
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch blockType {
+		case 4:
+			block := make([]byte, length)
+			if _, err := r.ReadAt(block, info.Start+4); err != nil {
+				return fmt.Errorf("truncated Vorbis comment")
+			}
+			parseVorbisComments(block, info.Tags)
+		case 6:
+			info.Tags["PICTURE"] = fmt.Sprintf("<%d bytes>", length)
+		}
+
+		info.Start += 4 + length
+		if last {
+			return nil
+		}
+	}
+}
+
+// parseVorbisComments records KEY=value pairs from a Vorbis comment block
+func parseVorbisComments(block []byte, tags map[string]string) {
+	if len(block) < 4 {
+		return
+	}
+	vendorLen := int(binary.LittleEndian.Uint32(block))
+	if 4+vendorLen+4 > len(block) {
+		return
+	}
+	tags["VENDOR"] = string(block[4 : 4+vendorLen])
+	block = block[4+vendorLen:]
+
+	count := int(binary.LittleEndian.Uint32(block))
+	block = block[4:]
+	for i := 0; i < count && len(block) >= 4; i++ {
+		n := int(binary.LittleEndian.Uint32(block))
+		if 4+n > len(block) {
+			return
+		}
+		comment := block[4 : 4+n]
+		block = block[4+n:]
+
+		if eq := bytes.IndexByte(comment, '='); eq > 0 {
+			key := strings.ToUpper(string(comment[:eq]))
+			value := string(comment[eq+1:])
+			if prev, ok := tags[key]; ok {
+				value = prev + "; " + value
+			}
+			tags[key] = value
+		}
+	}
+}
+
+// syncsafe decodes a 28-bit ID3v2 syncsafe integer
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return strings.TrimRight(string(runes), "\x00")
+}
@@ -0,0 +1,140 @@
+package audio
+
+import (
+	"bytes"
+	"dupe-file-checker/pkg/scanner"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var frames = bytes.Repeat([]byte{0xFF, 0xFB, 0x90, 0x64, 0x01, 0x02, 0x03}, 50)
+
+// id3v23 builds an ID3v2.3 tag holding a single latin-1 title frame
+func id3v23(title string) []byte {
+	frame := append([]byte("TIT2"), 0, 0, 0, byte(len(title)+1), 0, 0, 0)
+	frame = append(frame, title...)
+
+	size := len(frame)
+	header := []byte{'I', 'D', '3', 3, 0, 0,
+		byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+	return append(header, frame...)
+}
+
+// id3v1 builds a 128 byte ID3v1 trailer
+func id3v1(title string) []byte {
+	tag := make([]byte, 128)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	return tag
+}
+
+// flac builds a FLAC file with a STREAMINFO block and a Vorbis comment block
+func flac(comments ...string) []byte {
+	var vc bytes.Buffer
+	binary.Write(&vc, binary.LittleEndian, uint32(4))
+	vc.WriteString("test")
+	binary.Write(&vc, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&vc, binary.LittleEndian, uint32(len(c)))
+		vc.WriteString(c)
+	}
+
+	out := []byte("fLaC")
+	out = append(out, 0x00, 0, 0, 34)
+	out = append(out, make([]byte, 34)...)
+	out = append(out, 0x84, 0, byte(vc.Len()>>8), byte(vc.Len()))
+	out = append(out, vc.Bytes()...)
+	return append(out, frames...)
+}
+
+func writeFile(t *testing.T, dir, name string, parts ...[]byte) scanner.FileInfo {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	data := bytes.Join(parts, nil)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+	return scanner.FileInfo{Path: path, Size: int64(len(data))}
+}
+
+func TestComputeSkipsID3Tags(t *testing.T) {
+	tmpDir := t.TempDir()
+	bare := writeFile(t, tmpDir, "bare.mp3", frames)
+	tagged := writeFile(t, tmpDir, "tagged.mp3", id3v23("Song"), frames, id3v1("Song"))
+
+	a, err := Compute(bare.Path)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	b, err := Compute(tagged.Path)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+
+	if a.Hash != b.Hash {
+		t.Error("Expected identical audio hashes despite tags")
+	}
+	if b.End-b.Start != int64(len(frames)) {
+		t.Errorf("Payload length = %d; want %d", b.End-b.Start, len(frames))
+	}
+	if b.Tags["TIT2"] != "Song" || b.Tags["ID3v1 title"] != "Song" {
+		t.Errorf("Unexpected tags %v", b.Tags)
+	}
+}
+
+func TestComputeRejectsOversizedID3Tag(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Largest syncsafe size, far beyond the file
+	header := []byte{'I', 'D', '3', 3, 0, 0, 0x7F, 0x7F, 0x7F, 0x7F}
+	f := writeFile(t, tmpDir, "corrupt.mp3", header, frames)
+
+	if _, err := Compute(f.Path); err == nil {
+		t.Error("Expected an error for an ID3v2 tag larger than the file")
+	}
+}
+
+func TestComputeSkipsFLACMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	a := writeFile(t, tmpDir, "a.flac", flac("TITLE=One"))
+	b := writeFile(t, tmpDir, "b.flac", flac("TITLE=Two", "ARTIST=Someone"))
+
+	ia, err := Compute(a.Path)
+	if err != nil {
+		t.Fatalf("Compute failed: %v", err)
+	}
+	ib, _ := Compute(b.Path)
+
+	if ia.Hash != ib.Hash {
+		t.Error("Expected identical FLAC audio hashes despite Vorbis comments")
+	}
+	if ib.Tags["ARTIST"] != "Someone" {
+		t.Errorf("Unexpected tags %v", ib.Tags)
+	}
+}
+
+func TestFindMatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []scanner.FileInfo{
+		writeFile(t, tmpDir, "one.mp3", id3v23("Original"), frames),
+		writeFile(t, tmpDir, "two.mp3", id3v23("Remastered"), frames),
+		writeFile(t, tmpDir, "other.mp3", id3v23("Original"), frames[:70]),
+		writeFile(t, tmpDir, "notes.txt", frames),
+	}
+
+	groups := FindMatches(scanner.New(), files)
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	if len(groups[0].Files) != 2 {
+		t.Errorf("Expected 2 files, got %v", groups[0].Files)
+	}
+	if groups[0].Kind != scanner.MatchAudio {
+		t.Errorf("Kind = %q; want %q", groups[0].Kind, scanner.MatchAudio)
+	}
+	want := `TIT2 differs: "Original" vs "Remastered"`
+	if len(groups[0].Details) != 1 || groups[0].Details[0] != want {
+		t.Errorf("Details = %v; want [%s]", groups[0].Details, want)
+	}
+}
@@ -0,0 +1,96 @@
+package audio
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FindMatches groups MP3 and FLAC files whose audio frames are identical,
+// noting which tag fields differ between the copies
+func FindMatches(s *scanner.Scanner, files []scanner.FileInfo) []scanner.DuplicateGroup {
+	var candidates []scanner.FileInfo
+	for _, f := range files {
+		if IsAudio(f.Path) {
+			candidates = append(candidates, f)
+		}
+	}
+
+	var infos sync.Map
+	matches := s.GroupBy(candidates, func(f scanner.FileInfo) (string, error) {
+		info, err := Compute(f.Path)
+		if err != nil {
+			return "", err
+		}
+		infos.Store(f.Path, info)
+		return fmt.Sprintf("%d-%s", info.End-info.Start, strconv.FormatUint(info.Hash, 16)), nil
+	})
+
+	var groups []scanner.DuplicateGroup
+	for _, members := range matches {
+		var memberInfos []Info
+		for _, f := range members {
+			info, _ := infos.Load(f.Path)
+			memberInfos = append(memberInfos, info.(Info))
+		}
+
+		group := scanner.NewGroup(scanner.MatchAudio, memberInfos[0].Hash, members)
+		group.Details = tagDifferences(memberInfos)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
+
+// tagDifferences lists the tag fields that are missing from some copies or
+// hold different values
+func tagDifferences(infos []Info) []string {
+	fields := make(map[string]bool)
+	for _, info := range infos {
+		for field := range info.Tags {
+			fields[field] = true
+		}
+	}
+
+	var sorted []string
+	for field := range fields {
+		sorted = append(sorted, field)
+	}
+	sort.Strings(sorted)
+
+	var details []string
+	for _, field := range sorted {
+		present := 0
+		values := make(map[string]bool)
+		var shown []string
+		for _, info := range infos {
+			value, ok := info.Tags[field]
+			if !ok {
+				continue
+			}
+			present++
+			if !values[value] {
+				values[value] = true
+				shown = append(shown, strconv.Quote(value))
+			}
+		}
+
+		switch {
+		case present < len(infos):
+			details = append(details, fmt.Sprintf("%s present in %d of %d files", field, present, len(infos)))
+		case len(values) > 1:
+			details = append(details, fmt.Sprintf("%s differs: %s", field, strings.Join(shown, " vs ")))
+		}
+	}
+
+	if len(details) == 0 {
+		details = append(details, "tags identical")
+	}
+	return details
+}
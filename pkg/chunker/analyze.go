@@ -0,0 +1,186 @@
+package chunker
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Stats summarises what a chunk-level dedup store would hold for a set of files
+type Stats struct {
+	Files        int
+	Chunks       int
+	UniqueChunks int
+	TotalBytes   int64
+	UniqueBytes  int64
+}
+
+// SavedBytes is how much storage chunk-level dedup would save
+func (s Stats) SavedBytes() int64 {
+	return s.TotalBytes - s.UniqueBytes
+}
+
+// SavedRatio is SavedBytes as a share of TotalBytes
+func (s Stats) SavedRatio() float64 {
+	if s.TotalBytes == 0 {
+		return 0
+	}
+	return float64(s.SavedBytes()) / float64(s.TotalBytes)
+}
+
+// DirStats is the dedup estimate for one top-level directory on its own
+type DirStats struct {
+	Path string
+	Stats
+}
+
+// Report holds the overall estimate and one per top-level directory
+type Report struct {
+	Options Options
+	Overall Stats
+	Dirs    []DirStats
+}
+
+// tally accumulates chunks into a Stats value
+type tally struct {
+	stats Stats
+	seen  map[uint64]bool
+}
+
+func newTally() *tally {
+	return &tally{seen: make(map[uint64]bool)}
+}
+
+func (t *tally) add(hash uint64, size int64) {
+	t.stats.Chunks++
+	t.stats.TotalBytes += size
+	if !t.seen[hash] {
+		t.seen[hash] = true
+		t.stats.UniqueChunks++
+		t.stats.UniqueBytes += size
+	}
+}
+
+type chunkRef struct {
+	hash uint64
+	size int64
+}
+
+// Analyze chunks every file and estimates dedup savings overall and for each
+// top-level directory under root. Unreadable files are skipped.
+func Analyze(root string, files []scanner.FileInfo, opts Options) (Report, error) {
+	if err := opts.Validate(); err != nil {
+		return Report{}, err
+	}
+
+	type result struct {
+		file   scanner.FileInfo
+		chunks []chunkRef
+	}
+
+	workChan := make(chan scanner.FileInfo, 100)
+	resultChan := make(chan result, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range workChan {
+				chunks, err := chunkFile(f.Path, opts)
+				if err != nil {
+					continue
+				}
+				resultChan <- result{file: f, chunks: chunks}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	go func() {
+		for _, f := range files {
+			workChan <- f
+		}
+		close(workChan)
+	}()
+
+	overall := newTally()
+	dirs := make(map[string]*tally)
+	for r := range resultChan {
+		dir := topLevelDir(root, r.file.Path)
+		if dirs[dir] == nil {
+			dirs[dir] = newTally()
+		}
+
+		overall.stats.Files++
+		dirs[dir].stats.Files++
+		for _, c := range r.chunks {
+			overall.add(c.hash, c.size)
+			dirs[dir].add(c.hash, c.size)
+		}
+	}
+
+	report := Report{Options: opts, Overall: overall.stats}
+	for path, t := range dirs {
+		report.Dirs = append(report.Dirs, DirStats{Path: path, Stats: t.stats})
+	}
+	sort.Slice(report.Dirs, func(i, j int) bool {
+		if report.Dirs[i].SavedBytes() != report.Dirs[j].SavedBytes() {
+			return report.Dirs[i].SavedBytes() > report.Dirs[j].SavedBytes()
+		}
+		return report.Dirs[i].Path < report.Dirs[j].Path
+	})
+
+	return report, nil
+}
+
+// chunkFile returns the hash and size of every chunk of a file
+func chunkFile(path string, opts Options) ([]chunkRef, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c, err := New(f, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunkRef
+	for {
+		chunk, err := c.Next()
+		if err != nil {
+			if err == io.EOF {
+				return chunks, nil
+			}
+			return nil, err
+		}
+		chunks = append(chunks, chunkRef{hash: xxhash.Sum64(chunk), size: int64(len(chunk))})
+	}
+}
+
+// topLevelDir names the first path component below root that holds path;
+// files directly in root are reported under "."
+func topLevelDir(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "."
+	}
+	parts := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+	if len(parts) < 2 {
+		return "."
+	}
+	return parts[0]
+}
@@ -0,0 +1,116 @@
+package chunker
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math/bits"
+	"math/rand"
+)
+
+// Options sets the chunk size bounds in bytes
+type Options struct {
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+// DefaultOptions matches common backup tools: 2 KiB / 8 KiB / 64 KiB
+var DefaultOptions = Options{MinSize: 2 * 1024, AvgSize: 8 * 1024, MaxSize: 64 * 1024}
+
+// Validate checks that the bounds are usable
+func (o Options) Validate() error {
+	if o.MinSize <= 0 || o.AvgSize < o.MinSize || o.MaxSize < o.AvgSize {
+		return errors.New("chunk sizes must satisfy 0 < min <= avg <= max")
+	}
+	return nil
+}
+
+// gear maps every byte to a random 64-bit value; a fixed seed keeps chunk
+// boundaries stable between runs
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	r := rand.New(rand.NewSource(0x46617374434443))
+	for i := range table {
+		table[i] = r.Uint64()
+	}
+	return table
+}()
+
+// Chunker splits a stream into content-defined chunks using FastCDC's
+// normalised chunking: a stricter mask before the average size and a looser
+// one after it keep chunk sizes close to the average
+type Chunker struct {
+	r     *bufio.Reader
+	opts  Options
+	maskS uint64
+	maskL uint64
+	buf   []byte
+}
+
+// New returns a Chunker reading from r
+func New(r io.Reader, opts Options) (*Chunker, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	avgBits := bits.Len(uint(opts.AvgSize)) - 1
+	return &Chunker{
+		r:     bufio.NewReaderSize(r, opts.MaxSize),
+		opts:  opts,
+		maskS: spreadMask(avgBits + 1),
+		maskL: spreadMask(avgBits - 1),
+		buf:   make([]byte, 0, opts.MaxSize),
+	}, nil
+}
+
+// spreadMask returns a mask with n one bits spread over the upper 48 bits,
+// so boundaries depend on a wider window of the rolling hash
+func spreadMask(n int) uint64 {
+	if n < 1 {
+		n = 1
+	}
+	var mask uint64
+	step := 48 / n
+	for i := 0; i < n; i++ {
+		mask |= 1 << uint(63-i*step)
+	}
+	return mask
+}
+
+// Next returns the next chunk, or io.EOF once the stream is exhausted. The
+// returned slice is only valid until the following call.
+func (c *Chunker) Next() ([]byte, error) {
+	c.buf = c.buf[:0]
+	var hash uint64
+
+	for len(c.buf) < c.opts.MaxSize {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.buf = append(c.buf, b)
+
+		n := len(c.buf)
+		if n < c.opts.MinSize {
+			continue
+		}
+		hash = (hash << 1) + gear[b]
+
+		mask := c.maskL
+		if n < c.opts.AvgSize {
+			mask = c.maskS
+		}
+		if hash&mask == 0 {
+			return c.buf, nil
+		}
+	}
+
+	if len(c.buf) == 0 {
+		return nil, io.EOF
+	}
+	return c.buf, nil
+}
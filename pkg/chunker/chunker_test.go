@@ -0,0 +1,136 @@
+package chunker
+
+import (
+	"bytes"
+	"dupe-file-checker/pkg/scanner"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var testOptions = Options{MinSize: 256, AvgSize: 1024, MaxSize: 4096}
+
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunkAll(t *testing.T, data []byte) [][]byte {
+	t.Helper()
+	c, err := New(bytes.NewReader(data), testOptions)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	var chunks [][]byte
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return chunks
+		}
+		if err != nil {
+			t.Fatalf("Next failed: %v", err)
+		}
+		chunks = append(chunks, append([]byte(nil), chunk...))
+	}
+}
+
+func TestChunkSizesWithinBounds(t *testing.T) {
+	data := randomData(1, 256*1024)
+	chunks := chunkAll(t, data)
+
+	var total int
+	for i, chunk := range chunks {
+		total += len(chunk)
+		if len(chunk) > testOptions.MaxSize {
+			t.Errorf("Chunk %d is %d bytes; max is %d", i, len(chunk), testOptions.MaxSize)
+		}
+		if len(chunk) < testOptions.MinSize && i != len(chunks)-1 {
+			t.Errorf("Chunk %d is %d bytes; min is %d", i, len(chunk), testOptions.MinSize)
+		}
+	}
+	if total != len(data) {
+		t.Errorf("Chunks cover %d bytes; want %d", total, len(data))
+	}
+
+	avg := total / len(chunks)
+	if avg < testOptions.AvgSize/2 || avg > testOptions.AvgSize*2 {
+		t.Errorf("Average chunk size %d is far from %d", avg, testOptions.AvgSize)
+	}
+}
+
+func TestChunksResyncAfterInsert(t *testing.T) {
+	data := randomData(2, 64*1024)
+	shifted := append([]byte("inserted prefix"), data...)
+
+	seen := make(map[string]bool)
+	for _, chunk := range chunkAll(t, data) {
+		seen[string(chunk)] = true
+	}
+
+	shared := 0
+	chunks := chunkAll(t, shifted)
+	for _, chunk := range chunks {
+		if seen[string(chunk)] {
+			shared++
+		}
+	}
+	if shared < len(chunks)-3 {
+		t.Errorf("Only %d of %d chunks survived an insert at the start", shared, len(chunks))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Options{MinSize: 10, AvgSize: 5, MaxSize: 20}).Validate(); err == nil {
+		t.Error("Expected error when avg < min")
+	}
+	if err := DefaultOptions.Validate(); err != nil {
+		t.Errorf("DefaultOptions invalid: %v", err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	tmpDir := t.TempDir()
+	base := randomData(3, 32*1024)
+	edited := append(append([]byte{}, base...), randomData(4, 8*1024)...)
+
+	paths := map[string][]byte{
+		"vms/disk1.img": base,
+		"vms/disk2.img": edited,
+		"logs/app.log":  randomData(5, 16*1024),
+		"readme.txt":    []byte("top level file"),
+	}
+	var files []scanner.FileInfo
+	for rel, data := range paths {
+		path := filepath.Join(tmpDir, rel)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, data, 0644)
+		files = append(files, scanner.FileInfo{Path: path, Size: int64(len(data))})
+	}
+
+	report, err := Analyze(tmpDir, files, testOptions)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	if report.Overall.Files != 4 {
+		t.Errorf("Files = %d; want 4", report.Overall.Files)
+	}
+	if report.Overall.TotalBytes != int64(32*1024+40*1024+16*1024+14) {
+		t.Errorf("TotalBytes = %d", report.Overall.TotalBytes)
+	}
+	// Most of disk1 reappears in disk2
+	if report.Overall.SavedBytes() < 28*1024 {
+		t.Errorf("SavedBytes = %d; want at least %d", report.Overall.SavedBytes(), 28*1024)
+	}
+
+	if len(report.Dirs) != 3 {
+		t.Fatalf("Expected 3 directory entries, got %d", len(report.Dirs))
+	}
+	if report.Dirs[0].Path != "vms" || report.Dirs[0].SavedBytes() != report.Overall.SavedBytes() {
+		t.Errorf("Expected vms to hold all savings, got %+v", report.Dirs[0])
+	}
+}
@@ -0,0 +1,168 @@
+package dedupe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// AuditEntry records one destructive operation. Each operation is logged
+// twice: with status "intent" right before it starts, then with its outcome
+// ("ok", "skipped" or "error"), so a crash in between still leaves a trace.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Action string    `json:"action"`
+	// Source is the duplicate acted on, Target the verified copy that was kept
+	Source string `json:"source"`
+	Target string `json:"target"`
+	// Quarantined is where a quarantined source was stored, for purge and
+	// restore entries
+	Quarantined string `json:"quarantined,omitempty"`
+	Size        int64  `json:"size"`
+	Freed       int64  `json:"bytes_freed"`
+	// Digest is the SHA-256 of the kept file, which the source matched byte
+	// for byte right before the operation
+	Digest string `json:"digest,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// auditSink stores audit entries; a JSON Lines file or syslog
+type auditSink interface {
+	Add(v any) error
+	Close() error
+}
+
+// AuditLog appends entries for every operation Execute, PurgeQuarantine,
+// RestoreQuarantine and RevertLinks perform
+type AuditLog struct {
+	sink auditSink
+	user string
+	err  error
+}
+
+// OpenAuditLog opens an append-only JSON Lines audit log. The target
+// "syslog" sends entries to the local syslog daemon instead.
+func OpenAuditLog(target string) (*AuditLog, error) {
+	var sink auditSink
+	var err error
+	if target == "syslog" {
+		sink, err = openSyslog()
+	} else {
+		sink, err = CreateRecorder(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLog{sink: sink, user: currentUser()}, nil
+}
+
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.sink.Close()
+}
+
+// Failed returns the error that stopped the log, if an entry could not be
+// written. A nil log never fails.
+func (a *AuditLog) Failed() error {
+	if a == nil {
+		return nil
+	}
+	return a.err
+}
+
+// perform runs op between an intent entry and an outcome entry. op is not
+// run when the intent cannot be written, nor once any entry has failed, so
+// nothing happens that the log does not account for. A nil log just runs op.
+func (a *AuditLog) perform(entry AuditEntry, op func() (int64, error)) (int64, error) {
+	if a == nil {
+		return op()
+	}
+	if a.err != nil {
+		return 0, Skip("stopped after the audit log could not be written")
+	}
+
+	entry.User = a.user
+	entry.Status = "intent"
+	if err := a.add(entry); err != nil {
+		return 0, Skip("not attempted, the audit log could not be written")
+	}
+
+	freed, err := op()
+	entry.Freed = freed
+	entry.Status = "ok"
+	var skip *SkipError
+	if errors.As(err, &skip) {
+		entry.Status, entry.Error = "skipped", skip.Reason
+	} else if err != nil {
+		entry.Status, entry.Error = "error", err.Error()
+	}
+	if auditErr := a.add(entry); auditErr != nil && err == nil {
+		err = fmt.Errorf("done, but not recorded in the audit log: %v", auditErr)
+	}
+	return freed, err
+}
+
+// add writes an entry stamped with the current time, remembering a failure
+func (a *AuditLog) add(entry AuditEntry) error {
+	entry.Time = time.Now().UTC()
+	if err := a.sink.Add(entry); err != nil {
+		a.err = err
+		return err
+	}
+	return nil
+}
+
+// planEntry describes applying action to dup from a dedupe plan
+func planEntry(action string, gp GroupPlan, dup, digest string) AuditEntry {
+	return AuditEntry{
+		Action: action,
+		Source: absPath(dup),
+		Target: absPath(gp.Keep),
+		Size:   gp.Group.Size,
+		Digest: digest,
+		Rule:   gp.Reason,
+	}
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// fileDigest returns the SHA-256 of a file as "sha256:<hex>"
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
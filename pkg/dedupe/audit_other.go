@@ -0,0 +1,11 @@
+//go:build windows || plan9
+
+package dedupe
+
+import (
+	"errors"
+)
+
+func openSyslog() (auditSink, error) {
+	return nil, errors.New("syslog is not available on this platform; give the audit log a file path")
+}
@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+
+package dedupe
+
+import (
+	"encoding/json"
+	"log/syslog"
+)
+
+// syslogSink writes each audit entry as one JSON message
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func openSyslog() (auditSink, error) {
+	w, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_AUTHPRIV, "dupe-checker")
+	if err != nil {
+		return nil, err
+	}
+	return syslogSink{w: w}, nil
+}
+
+func (s syslogSink) Add(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.w.Notice(string(data))
+}
+
+func (s syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,861 @@
+package dedupe
+
+import (
+	"dupe-file-checker/internal/testutil"
+	"dupe-file-checker/pkg/scanner"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStrategy(t *testing.T) {
+	for _, s := range Strategies {
+		if got, err := ParseStrategy(string(s)); err != nil || got != s {
+			t.Errorf("ParseStrategy(%q) = %q, %v", s, got, err)
+		}
+	}
+	if _, err := ParseStrategy("biggest"); err == nil {
+		t.Error("Expected error for unknown strategy")
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	files := []candidate{
+		{Path: "/b/archive/photo.jpg", ModTime: 200},
+		{Path: "/a/photo.jpg", ModTime: 300},
+		{Path: "/c/x/y/photo copy.jpg", ModTime: 100},
+	}
+	roots := []string{"/c", "/a", "/b"}
+
+	tests := []struct {
+		strategy Strategy
+		want     string
+	}{
+		{KeepOldest, "/c/x/y/photo copy.jpg"},
+		{KeepNewest, "/a/photo.jpg"},
+		{KeepShortestPath, "/a/photo.jpg"},
+		{KeepLongestPath, "/c/x/y/photo copy.jpg"},
+		{KeepFirstRoot, "/c/x/y/photo copy.jpg"},
+		{KeepAlphabetical, "/a/photo.jpg"},
+	}
+
+	for _, test := range tests {
+		keep, _, _ := StrategyPolicy(test.strategy).choose(files, roots)
+		if got := files[keep].Path; got != test.want {
+			t.Errorf("%s kept %s; want %s", test.strategy, got, test.want)
+		}
+	}
+}
+
+func TestNewPlanSkipsNonExactGroups(t *testing.T) {
+	groups := []scanner.DuplicateGroup{
+		{Kind: scanner.MatchExact, Size: 10, Files: []string{"/x/a", "/x/b"}},
+		{Kind: scanner.MatchText, Size: 10, Files: []string{"/x/c", "/x/d"}},
+		{Kind: scanner.MatchExact, Size: 10, Files: []string{"/x/e.zip!/f", "/x/f"}},
+	}
+
+	plan := NewPlan(groups, StrategyPolicy(KeepAlphabetical), nil, nil)
+	if len(plan.Groups) != 1 {
+		t.Fatalf("Expected 1 planned group, got %d", len(plan.Groups))
+	}
+	if plan.Groups[0].Keep != "/x/a" || len(plan.Groups[0].Remove) != 1 || plan.Groups[0].Remove[0] != "/x/b" {
+		t.Errorf("Unexpected plan %+v", plan.Groups[0])
+	}
+}
+
+func TestExecuteDelete(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "Duplicate content for dedupe"
+	testutil.CreateTestFile(filepath.Join(tmpDir, "a.txt"), content)
+	testutil.CreateTestFile(filepath.Join(tmpDir, "b.txt"), content)
+	testutil.CreateTestFile(filepath.Join(tmpDir, "nested", "c.txt"), content)
+
+	files, _ := scanner.Walk(tmpDir, false)
+	groups := scanner.New().ScanFiles(files, false)
+	plan := NewPlan(groups, StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+
+	dry := Execute(plan, Delete{}, Options{DryRun: true})
+	if dry.Files != 2 || dry.BytesReclaimed != int64(2*len(content)) {
+		t.Errorf("Dry run summary = %+v", dry)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "b.txt")); err != nil {
+		t.Fatal("Dry run must not delete files")
+	}
+
+	summary := Execute(plan, Delete{}, Options{})
+	if summary.Files != 2 || summary.Failed != 0 {
+		t.Errorf("Summary = %+v", summary)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "a.txt")); err != nil {
+		t.Error("Expected kept file to remain")
+	}
+	for _, removed := range []string{"b.txt", filepath.Join("nested", "c.txt")} {
+		if _, err := os.Stat(filepath.Join(tmpDir, removed)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be deleted", removed)
+		}
+	}
+}
+
+func TestDeleteSameFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	tmpDir := t.TempDir()
+	orig := filepath.Join(tmpDir, "real", "a.txt")
+	testutil.CreateTestFile(orig, "only copy")
+	if err := os.Symlink(filepath.Join(tmpDir, "real"), filepath.Join(tmpDir, "link")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	// The same file seen through a symlinked directory must never be deleted
+	var skip *SkipError
+	if _, err := (Delete{}).Apply(filepath.Join(tmpDir, "link", "a.txt"), orig, 9); !errors.As(err, &skip) {
+		t.Fatalf("Expected skip, got %v", err)
+	}
+	if _, err := os.Stat(orig); err != nil {
+		t.Fatal("Expected the only copy to remain")
+	}
+
+	// Deleting a path whose data is still linked elsewhere frees nothing
+	keep := filepath.Join(tmpDir, "keep.txt")
+	testutil.CreateTestFile(keep, "only copy")
+	os.Link(orig, filepath.Join(tmpDir, "other.txt"))
+	if freed, err := (Delete{}).Estimate(keep, orig, 9); err != nil || freed != 0 {
+		t.Errorf("Estimate = %d, %v; want 0", freed, err)
+	}
+}
+
+func TestExecuteHardLink(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "Duplicate content for hard linking"
+	keep := filepath.Join(tmpDir, "a.txt")
+	dup := filepath.Join(tmpDir, "b.txt")
+	testutil.CreateTestFile(keep, content)
+	testutil.CreateTestFile(dup, content)
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+
+	summary := Execute(plan, HardLink{}, Options{})
+	if summary.Files != 1 || summary.Failed != 0 || summary.BytesReclaimed != int64(len(content)) {
+		t.Fatalf("Summary = %+v", summary)
+	}
+
+	keepInfo, _ := os.Stat(keep)
+	dupInfo, err := os.Stat(dup)
+	if err != nil {
+		t.Fatalf("Expected linked path to remain: %v", err)
+	}
+	if !os.SameFile(keepInfo, dupInfo) {
+		t.Error("Expected duplicate to be a hard link to the kept file")
+	}
+
+	// A second run has nothing left to reclaim
+	again := Execute(plan, HardLink{}, Options{DryRun: true})
+	if again.Skipped != 1 || again.BytesReclaimed != 0 {
+		t.Errorf("Expected already-linked file to be skipped, got %+v", again)
+	}
+}
+
+func TestDryRunMatchesRunForLinkedCopies(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("link counts are not read on Windows")
+	}
+	content := "Duplicate content stored under two hard linked names"
+	size := int64(len(content))
+
+	for _, action := range []Action{Delete{}, HardLink{}} {
+		tmpDir := t.TempDir()
+		testutil.CreateTestFile(filepath.Join(tmpDir, "a.txt"), content)
+		testutil.CreateTestFile(filepath.Join(tmpDir, "b.txt"), content)
+		os.Link(filepath.Join(tmpDir, "b.txt"), filepath.Join(tmpDir, "c.txt"))
+
+		files, _ := scanner.Walk(tmpDir, false)
+		plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+
+		// b.txt and c.txt share their data, which goes with the second of them
+		dry := Execute(plan, action, Options{DryRun: true})
+		if dry.Files != 2 || dry.BytesReclaimed != size {
+			t.Errorf("%s dry run = %+v; want 2 files and %d bytes", action.Name(), dry, size)
+		}
+		real := Execute(plan, action, Options{})
+		if real.BytesReclaimed != dry.BytesReclaimed {
+			t.Errorf("%s freed %d bytes; the dry run said %d", action.Name(), real.BytesReclaimed, dry.BytesReclaimed)
+		}
+	}
+}
+
+func TestExecuteReflink(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "Duplicate content for cloning"
+	keep := filepath.Join(tmpDir, "a.txt")
+	dup := filepath.Join(tmpDir, "b.txt")
+	testutil.CreateTestFile(keep, content)
+	testutil.CreateTestFile(dup, content)
+	os.Chmod(dup, 0600)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(dup, mtime, mtime)
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+
+	// Most test filesystems cannot clone; either outcome must leave an
+	// intact, independent copy behind
+	summary := Execute(plan, Reflink{}, Options{})
+	if summary.Failed != 0 || summary.Files+summary.Skipped != 1 {
+		t.Fatalf("Summary = %+v", summary)
+	}
+	if summary.Skipped == 1 && summary.BytesReclaimed != 0 {
+		t.Error("Skipped clones must not report savings")
+	}
+
+	data, err := os.ReadFile(dup)
+	if err != nil || string(data) != content {
+		t.Fatalf("Duplicate content changed: %q, %v", data, err)
+	}
+	keepInfo, _ := os.Stat(keep)
+	dupInfo, _ := os.Stat(dup)
+	if os.SameFile(keepInfo, dupInfo) {
+		t.Error("Reflink must not hard link the files")
+	}
+	if dupInfo.Mode().Perm() != 0600 || !dupInfo.ModTime().Equal(mtime) {
+		t.Errorf("Metadata not preserved: mode %v, mtime %v", dupInfo.Mode().Perm(), dupInfo.ModTime())
+	}
+}
+
+func TestSymlinkAndRevert(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "Duplicate content for symlinking"
+	keep := filepath.Join(tmpDir, "a.txt")
+	dup := filepath.Join(tmpDir, "nested", "b.txt")
+	testutil.CreateTestFile(keep, content)
+	testutil.CreateTestFile(dup, content)
+
+	recorder, err := CreateRecorder(filepath.Join(t.TempDir(), "links.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	action := Symlink{Roots: []string{tmpDir}, Record: recorder}
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+	summary := Execute(plan, action, Options{})
+	recorder.Close()
+	if summary.Files != 1 || summary.Failed != 0 {
+		t.Fatalf("Summary = %+v", summary)
+	}
+
+	target, err := os.Readlink(dup)
+	if err != nil {
+		t.Fatalf("Expected a symlink: %v", err)
+	}
+	if target != filepath.Join("..", "a.txt") {
+		t.Errorf("Expected relative target, got %s", target)
+	}
+
+	reverted, err := RevertLinks(recorder.file.Name(), nil)
+	if err != nil || reverted.Files != 1 {
+		t.Fatalf("RevertLinks = %+v, %v", reverted, err)
+	}
+	info, err := os.Lstat(dup)
+	if err != nil || !info.Mode().IsRegular() {
+		t.Fatalf("Expected a regular file after revert, got %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(dup); string(data) != content {
+		t.Errorf("Reverted content = %q", data)
+	}
+}
+
+func TestSymlinkRefusesOutsideRoots(t *testing.T) {
+	inside := t.TempDir()
+	outside := t.TempDir()
+	keep := filepath.Join(outside, "a.txt")
+	dup := filepath.Join(inside, "b.txt")
+	testutil.CreateTestFile(keep, "same")
+	testutil.CreateTestFile(dup, "same")
+
+	_, err := Symlink{Roots: []string{inside}}.Apply(keep, dup, 4)
+	var skip *SkipError
+	if !errors.As(err, &skip) {
+		t.Fatalf("Expected link outside roots to be skipped, got %v", err)
+	}
+	if info, _ := os.Lstat(dup); !info.Mode().IsRegular() {
+		t.Error("Refused duplicate must be left unchanged")
+	}
+}
+
+func TestQuarantineRestoreAndPurge(t *testing.T) {
+	tmpDir := t.TempDir()
+	qDir := filepath.Join(t.TempDir(), "quarantine")
+	content := "Duplicate content for quarantine"
+	keep := filepath.Join(tmpDir, "a.txt")
+	dup := filepath.Join(tmpDir, "nested", "b.txt")
+	testutil.CreateTestFile(keep, content)
+	testutil.CreateTestFile(dup, content)
+
+	q, err := OpenQuarantine(qDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+	summary := Execute(plan, q, Options{})
+	q.Close()
+	if summary.Files != 1 || summary.Failed != 0 {
+		t.Fatalf("Summary = %+v", summary)
+	}
+	// Nothing is freed until the quarantine is purged
+	if summary.BytesReclaimed != 0 || summary.BytesMoved != int64(len(content)) {
+		t.Errorf("Expected %d bytes moved and none reclaimed, got %+v", len(content), summary)
+	}
+
+	if _, err := os.Stat(dup); !os.IsNotExist(err) {
+		t.Fatal("Expected duplicate to be moved away")
+	}
+	mirrored := q.mirrorPath(dup)
+	if data, err := os.ReadFile(mirrored); err != nil || string(data) != content {
+		t.Fatalf("Expected mirrored copy at %s: %v", mirrored, err)
+	}
+
+	restored, err := RestoreQuarantine(qDir, nil)
+	if err != nil || restored.Files != 1 {
+		t.Fatalf("RestoreQuarantine = %+v, %v", restored, err)
+	}
+	if data, err := os.ReadFile(dup); err != nil || string(data) != content {
+		t.Fatalf("Expected duplicate to be restored: %v", err)
+	}
+
+	// Quarantine again, then purge: too young first, old enough later
+	q, _ = OpenQuarantine(qDir)
+	Execute(plan, q, Options{})
+	q.Close()
+
+	young, err := PurgeQuarantine(qDir, 24*time.Hour, time.Now(), nil)
+	if err != nil || young.Files != 0 {
+		t.Fatalf("Expected nothing purged yet, got %+v, %v", young, err)
+	}
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purged, err := PurgeQuarantine(qDir, 24*time.Hour, time.Now().Add(48*time.Hour), audit)
+	audit.Close()
+	if err != nil || purged.Files != 1 || purged.BytesReclaimed != int64(len(content)) {
+		t.Fatalf("PurgeQuarantine = %+v, %v", purged, err)
+	}
+	entries, _ := readRecords[AuditEntry](logPath)
+	if len(entries) != 2 || entries[0].Status != "intent" || entries[1].Status != "ok" ||
+		entries[1].Action != "purge" || entries[1].Quarantined != mirrored || entries[1].Freed != int64(len(content)) {
+		t.Errorf("Unexpected purge audit entries %+v", entries)
+	}
+	if _, err := os.Stat(mirrored); !os.IsNotExist(err) {
+		t.Error("Expected purged file to be gone")
+	}
+	if records, _ := readRecords[QuarantineRecord](filepath.Join(qDir, quarantineJournal)); len(records) != 0 {
+		t.Errorf("Expected empty journal, got %d records", len(records))
+	}
+}
+
+func TestPurgeQuarantineChecksJournal(t *testing.T) {
+	tmpDir := t.TempDir()
+	qDir := filepath.Join(tmpDir, "q")
+	q, err := OpenQuarantine(qDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outside := filepath.Join(tmpDir, "outside.txt")
+	gone := filepath.Join(qDir, "files", "gone.txt")
+	changed := filepath.Join(qDir, "files", "changed.txt")
+	keepChanged := filepath.Join(tmpDir, "changed.txt")
+	for _, path := range []string{outside, gone, changed} {
+		testutil.CreateTestFile(path, "content")
+	}
+	testutil.CreateTestFile(keepChanged, "edited since")
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, rec := range []QuarantineRecord{
+		{Original: "/x/outside.txt", Quarantined: outside, Keep: keepChanged, Size: 7, MovedAt: old},
+		{Original: "/x/gone.txt", Quarantined: gone, Keep: filepath.Join(tmpDir, "deleted.txt"), Size: 7, MovedAt: old},
+		{Original: "/x/changed.txt", Quarantined: changed, Keep: keepChanged, Size: 7, MovedAt: old},
+	} {
+		q.journal.Add(rec)
+	}
+	q.Close()
+
+	summary, err := PurgeQuarantine(qDir, 24*time.Hour, time.Now(), nil)
+	if err != nil || summary.Files != 0 || summary.Skipped != 3 {
+		t.Fatalf("PurgeQuarantine = %+v, %v", summary, err)
+	}
+	for _, path := range []string{outside, gone, changed} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be left alone", path)
+		}
+	}
+	if records, _ := readRecords[QuarantineRecord](filepath.Join(qDir, quarantineJournal)); len(records) != 3 {
+		t.Errorf("Expected skipped entries to stay in the journal, got %d", len(records))
+	}
+}
+
+func TestQuarantineNeverKeepsQuarantinedCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	q := &Quarantine{Dir: filepath.Join(tmpDir, ".q")}
+	keep := filepath.Join(tmpDir, ".q", "files", "old", "b.txt")
+	dup := filepath.Join(tmpDir, "a.txt")
+	testutil.CreateTestFile(keep, "last copy")
+	testutil.CreateTestFile(dup, "last copy")
+
+	var skip *SkipError
+	if _, err := q.Estimate(keep, dup, 9); !errors.As(err, &skip) {
+		t.Errorf("Expected skip when the kept file is quarantined, got %v", err)
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d": 30 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := ParseAge(in); err != nil || got != want {
+			t.Errorf("ParseAge(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseAge("soon"); err == nil {
+		t.Error("Expected error for invalid age")
+	}
+}
+
+func TestTrash(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("freedesktop trash is Unix only")
+	}
+
+	tmpDir := t.TempDir()
+	trash := Trash{Home: filepath.Join(tmpDir, "Trash")}
+	keep := filepath.Join(tmpDir, "kept.txt")
+	dup := filepath.Join(tmpDir, "my file.txt")
+	testutil.CreateTestFile(keep, "trash me")
+	testutil.CreateTestFile(dup, "trash me")
+
+	if _, err := trash.Apply(keep, dup, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dup); !os.IsNotExist(err) {
+		t.Fatal("Expected file to be moved to the trash")
+	}
+	if data, err := os.ReadFile(filepath.Join(trash.Home, "files", "my file.txt")); err != nil || string(data) != "trash me" {
+		t.Fatalf("Expected trashed file, got %q, %v", data, err)
+	}
+
+	info, err := os.ReadFile(filepath.Join(trash.Home, "info", "my file.txt.trashinfo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPath := "Path=" + strings.ReplaceAll(filepath.ToSlash(dup), " ", "%20") + "\n"
+	if !strings.HasPrefix(string(info), "[Trash Info]\n") || !strings.Contains(string(info), wantPath) ||
+		!strings.Contains(string(info), "DeletionDate=") {
+		t.Errorf("Unexpected trashinfo:\n%s", info)
+	}
+
+	// A second file with the same name gets a new one
+	testutil.CreateTestFile(dup, "trash me")
+	if _, err := trash.Apply(keep, dup, 8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(trash.Home, "info", "my file.txt.2.trashinfo")); err != nil {
+		t.Errorf("Expected renamed trash entry: %v", err)
+	}
+
+	// Trashing frees nothing, and a trashed copy is never the one kept
+	testutil.CreateTestFile(dup, "trash me")
+	if freed, err := trash.Estimate(keep, dup, 8); err != nil || freed != 0 {
+		t.Errorf("Estimate = %d, %v; want 0", freed, err)
+	}
+	var skip *SkipError
+	if _, err := trash.Estimate(filepath.Join(trash.Home, "files", "my file.txt"), dup, 8); !errors.As(err, &skip) {
+		t.Errorf("Expected skip when the kept file is in the trash, got %v", err)
+	}
+	if dirs := trash.Dirs([]string{tmpDir}); len(dirs) == 0 || dirs[0] != trash.Home {
+		t.Errorf("Dirs = %v; want the home trash first", dirs)
+	}
+}
+
+func TestNewPlanLeavesTrashAlone(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(tmpDir, "data"))
+	trashed := filepath.Join(tmpDir, "data", "Trash", "files", "a.txt")
+	mountTrashed := filepath.Join(tmpDir, ".Trash-"+strconv.Itoa(os.Getuid()), "files", "a.txt")
+	keep := filepath.Join(tmpDir, "b.txt")
+	dup := filepath.Join(tmpDir, "c.txt")
+	for _, path := range []string{trashed, mountTrashed, keep, dup} {
+		testutil.CreateTestFile(path, "same content")
+	}
+	// The trashed copies are the oldest, which would make them the keepers
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(trashed, old, old)
+	os.Chtimes(mountTrashed, old, old)
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepOldest), []string{tmpDir}, nil)
+	if len(plan.Groups) != 1 {
+		t.Fatalf("Expected 1 group, got %+v", plan)
+	}
+	gp := plan.Groups[0]
+	if gp.Keep == trashed || gp.Keep == mountTrashed || len(gp.Remove) != 1 {
+		t.Errorf("Expected trashed copies to be left out, got keep %s and remove %v", gp.Keep, gp.Remove)
+	}
+}
+
+func TestWriteScript(t *testing.T) {
+	keep, _ := filepath.Abs("/data/it's.txt")
+	dup, _ := filepath.Abs("/data/copy $(x).txt")
+	plan := Plan{Groups: []GroupPlan{{
+		Group: scanner.DuplicateGroup{
+			Size:  42,
+			Files: []string{keep, dup},
+			Infos: []scanner.FileInfo{{Path: keep, Size: 42, ModTime: 100}, {Path: dup, Size: 42, ModTime: 200}},
+		},
+		Keep:   keep,
+		Remove: []string{dup},
+	}}}
+
+	var b strings.Builder
+	if err := WriteScript(&b, plan, ScriptOptions{}, time.Unix(0, 0)); err != nil {
+		t.Fatal(err)
+	}
+	script := b.String()
+
+	for _, want := range []string{
+		"#!/bin/sh\n",
+		"check " + shellQuote(keep) + " 42 100\n",
+		"check " + shellQuote(dup) + " 42 200\n",
+		"rm -f -- " + shellQuote(dup) + "\n",
+	} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Script missing %q:\n%s", want, script)
+		}
+	}
+	if shellQuote("it's") != `'it'\''s'` {
+		t.Errorf("shellQuote(\"it's\") = %s", shellQuote("it's"))
+	}
+
+	if err := WriteScript(&b, plan, ScriptOptions{Link: "reflink"}, time.Now()); err == nil {
+		t.Error("Expected reflink scripts to be rejected")
+	}
+}
+
+func TestWriteScriptSkipsCrossDeviceLinks(t *testing.T) {
+	other, err := os.MkdirTemp("/dev/shm", "dupe-script")
+	if err != nil {
+		t.Skip("no second filesystem available")
+	}
+	defer os.RemoveAll(other)
+
+	keep := filepath.Join(t.TempDir(), "a.txt")
+	dup := filepath.Join(other, "b.txt")
+	testutil.CreateTestFile(keep, "content")
+	testutil.CreateTestFile(dup, "content")
+	keepInfo, _ := os.Stat(keep)
+	dupInfo, _ := os.Stat(dup)
+	if sameDevice(keep, dup, keepInfo, dupInfo) {
+		t.Skip("/dev/shm is on the same filesystem")
+	}
+
+	plan := Plan{Groups: []GroupPlan{{
+		Group:  scanner.DuplicateGroup{Size: 7, Files: []string{keep, dup}},
+		Keep:   keep,
+		Remove: []string{dup},
+	}}}
+	var b strings.Builder
+	if err := WriteScript(&b, plan, ScriptOptions{Link: "hard"}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), "ln -f") || !strings.Contains(b.String(), "# skipped: different filesystem") {
+		t.Errorf("Expected the cross-device link to be skipped:\n%s", b.String())
+	}
+}
+
+func TestExecuteRevalidates(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(dup string, mtime time.Time)
+		reason string
+	}{
+		{"edited", func(dup string, mtime time.Time) {
+			os.WriteFile(dup, []byte("Duplicate content for validatioN"), 0644)
+			os.Chtimes(dup, mtime, mtime)
+		}, "no longer matches"},
+		{"touched", func(dup string, mtime time.Time) {
+			later := mtime.Add(time.Hour)
+			os.Chtimes(dup, later, later)
+		}, "was modified"},
+		{"replaced", func(dup string, mtime time.Time) {
+			// Written next to it first, so it cannot reuse the old inode
+			tmp := dup + ".new"
+			os.WriteFile(tmp, []byte("Duplicate content for validation"), 0644)
+			os.Chtimes(tmp, mtime, mtime)
+			os.Rename(tmp, dup)
+		}, "was replaced"},
+		{"removed", func(dup string, mtime time.Time) {
+			os.Remove(dup)
+		}, "is gone"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.name == "replaced" && runtime.GOOS == "windows" {
+				t.Skip("inode numbers are not recorded on Windows")
+			}
+
+			tmpDir := t.TempDir()
+			keep := filepath.Join(tmpDir, "a.txt")
+			dup := filepath.Join(tmpDir, "b.txt")
+			testutil.CreateTestFile(keep, "Duplicate content for validation")
+			testutil.CreateTestFile(dup, "Duplicate content for validation")
+			mtime := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			os.Chtimes(dup, mtime, mtime)
+
+			files, _ := scanner.Walk(tmpDir, false)
+			plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+			test.change(dup, mtime)
+
+			summary := Execute(plan, Delete{}, Options{})
+			if summary.Skipped != 1 || summary.Files != 0 {
+				t.Fatalf("Expected the group to be skipped, got %+v", summary)
+			}
+			if err := summary.Results[0].Err; !strings.Contains(err.Error(), test.reason) {
+				t.Errorf("Skip reason %q does not mention %q", err, test.reason)
+			}
+			if _, err := os.Stat(keep); err != nil {
+				t.Error("Kept file must be untouched")
+			}
+		})
+	}
+}
+
+func TestExecuteRejectsSameFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(filepath.Join(tmpDir, "real", "a.txt"), "only copy")
+	if err := os.Symlink(filepath.Join(tmpDir, "real"), filepath.Join(tmpDir, "link")); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	// Walked separately, the same file shows up under both roots
+	realFiles, _ := scanner.Walk(filepath.Join(tmpDir, "real"), false)
+	linkFiles, _ := scanner.Walk(filepath.Join(tmpDir, "link")+"/", false)
+	group := scanner.NewGroup(scanner.MatchExact, 1, append(linkFiles, realFiles...))
+	gp := GroupPlan{Group: group, Keep: group.Files[0], Remove: group.Files[1:]}
+
+	summary := Execute(Plan{Groups: []GroupPlan{gp}}, Delete{}, Options{})
+	if summary.Skipped != 1 || summary.Files != 0 {
+		t.Fatalf("Expected the group to be skipped, got %+v", summary)
+	}
+	if err := summary.Results[0].Err; !strings.Contains(err.Error(), "under another path") {
+		t.Errorf("Unexpected skip reason %q", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "real", "a.txt")); err != nil {
+		t.Error("Expected the only copy to remain")
+	}
+}
+
+func TestProtector(t *testing.T) {
+	protect, err := NewProtector([]string{"/data/master/**", "*.keep", "/srv/originals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]bool{
+		"/data/master/a.jpg":          true,
+		"/data/master/2020/x/b.jpg":   true,
+		"/data/masters/a.jpg":         false,
+		"/tmp/notes.keep":             true,
+		"/tmp/notes.keep.bak":         false,
+		"/srv/originals":              true,
+		"/srv/originals/deep/c.txt":   true,
+		"/srv/originals-old/c.txt":    false,
+		"/data/incoming/master/a.jpg": false,
+	}
+	for path, want := range tests {
+		if got := protect.Protected(filepath.FromSlash(path)); got != want {
+			t.Errorf("Protected(%s) = %v; want %v", path, got, want)
+		}
+	}
+
+	if _, err := NewProtector([]string{"/data/[master"}); err == nil {
+		t.Error("Expected error for malformed glob")
+	}
+	if (*Protector)(nil).Protected("/any") {
+		t.Error("A nil protector must protect nothing")
+	}
+}
+
+func TestNewPlanHonoursProtection(t *testing.T) {
+	protect, _ := NewProtector([]string{"/master/**"})
+	groups := []scanner.DuplicateGroup{
+		{Kind: scanner.MatchExact, Size: 10, Files: []string{"/a/x", "/master/x", "/master/y"}},
+		{Kind: scanner.MatchExact, Size: 10, Files: []string{"/master/p", "/master/q"}},
+	}
+
+	// Alphabetical would keep /a/x and remove both protected copies
+	plan := NewPlan(groups, StrategyPolicy(KeepAlphabetical), nil, protect)
+	if len(plan.Groups) != 1 || len(plan.Informational) != 1 {
+		t.Fatalf("Plan = %+v", plan)
+	}
+	gp := plan.Groups[0]
+	if gp.Keep != "/master/x" || len(gp.Remove) != 1 || gp.Remove[0] != "/a/x" {
+		t.Errorf("Expected protected copies to survive, got %+v", gp)
+	}
+
+	// Execute refuses protected files even if a plan offers them
+	gp.Keep, gp.Remove = "/a/x", []string{"/master/x"}
+	summary := Execute(Plan{Groups: []GroupPlan{gp}}, Delete{}, Options{DryRun: true, Protect: protect})
+	if summary.Skipped != 1 || summary.Files != 0 {
+		t.Errorf("Expected protected file to be skipped, got %+v", summary)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy, err := ParsePolicy([]byte(`{"rules": [
+		{"never": "path", "match": "^/tmp/"},
+		{"prefer": "path", "match": "/archive/"},
+		{"avoid": "name", "match": "copy|\\(1\\)"},
+		{"prefer": "oldest"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		files  []candidate
+		want   string
+		reason string
+	}{
+		{
+			[]candidate{{Path: "/tmp/a.jpg", ModTime: 1}, {Path: "/b/a.jpg", ModTime: 5}},
+			"/b/a.jpg", "rule 1: never keep path matching ^/tmp/",
+		},
+		{
+			[]candidate{{Path: "/x/a.jpg"}, {Path: "/y/archive/a.jpg"}, {Path: "/z/a.jpg"}},
+			"/y/archive/a.jpg", "rule 2: prefer path matching /archive/",
+		},
+		{
+			[]candidate{{Path: "/x/a copy.jpg"}, {Path: "/x/a (1).jpg"}, {Path: "/x/z.jpg"}},
+			"/x/z.jpg", "rule 3: avoid name matching copy|\\(1\\)",
+		},
+		{
+			[]candidate{{Path: "/x/a.jpg", ModTime: 9}, {Path: "/x/b.jpg", ModTime: 3}},
+			"/x/b.jpg", "rule 4: prefer oldest",
+		},
+		{
+			[]candidate{{Path: "/x/b.jpg", ModTime: 3}, {Path: "/x/a.jpg", ModTime: 3}},
+			"/x/a.jpg", "alphabetical tie-break",
+		},
+	}
+	for _, test := range tests {
+		keep, reason, ok := policy.choose(test.files, nil)
+		if !ok || test.files[keep].Path != test.want || reason != test.reason {
+			t.Errorf("choose kept %s (%s, %v); want %s (%s)", test.files[keep].Path, reason, ok, test.want, test.reason)
+		}
+	}
+
+	if _, _, ok := policy.choose([]candidate{{Path: "/tmp/a"}, {Path: "/tmp/b"}}, nil); ok {
+		t.Error("Expected no keeper when every copy is ruled out")
+	}
+
+	// Path rules see absolute paths, however the root was typed
+	tmpDir := t.TempDir()
+	relative, err := ParsePolicy([]byte(`{"rules": [{"never": "path", "match": "^` + filepath.ToSlash(tmpDir) + `/pt/a/"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(tmpDir)
+	files := []candidate{{Path: filepath.Join("pt", "a", "x")}, {Path: filepath.Join("pt", "b", "x")}}
+	if keep, _, ok := relative.choose(files, nil); !ok || keep != 1 {
+		t.Errorf("Expected the never rule to apply to relative paths, kept %s", files[keep].Path)
+	}
+
+	for _, bad := range []string{
+		`{"rules": []}`,
+		`{"rules": [{"prefer": "biggest"}]}`,
+		`{"rules": [{"prefer": "oldest", "avoid": "name", "match": "x"}]}`,
+		`{"rules": [{"never": "dir", "match": "x"}]}`,
+		`{"rules": [{"avoid": "name", "match": "("}]}`,
+	} {
+		if _, err := ParsePolicy([]byte(bad)); err == nil {
+			t.Errorf("Expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestExecuteAuditLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := "Duplicate content for auditing"
+	testutil.CreateTestFile(filepath.Join(tmpDir, "a.txt"), content)
+	testutil.CreateTestFile(filepath.Join(tmpDir, "b.txt"), content)
+	testutil.CreateTestFile(filepath.Join(tmpDir, "c.txt"), content)
+
+	logPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	audit, err := OpenAuditLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+	summary := Execute(plan, Delete{}, Options{Audit: audit})
+	audit.Close()
+	if summary.Files != 2 {
+		t.Fatalf("Summary = %+v", summary)
+	}
+
+	entries, err := readRecords[AuditEntry](logPath)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("Expected an intent and an outcome entry per file, got %d, %v", len(entries), err)
+	}
+	if entries[0].Status != "intent" || entries[0].Source != entries[1].Source {
+		t.Errorf("Expected an intent entry before the operation, got %+v", entries[0])
+	}
+	e := entries[1]
+	if e.Action != "delete" || e.Status != "ok" || e.Target != filepath.Join(tmpDir, "a.txt") ||
+		e.Source != filepath.Join(tmpDir, "b.txt") || e.Size != int64(len(content)) ||
+		!strings.HasPrefix(e.Digest, "sha256:") || e.Rule != "prefer alphabetical" || e.User == "" {
+		t.Errorf("Unexpected audit entry %+v", e)
+	}
+}
+
+func TestExecuteStopsWhenAuditFails(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"x", "y"} {
+		testutil.CreateTestFile(filepath.Join(tmpDir, dir, "a.txt"), "first group")
+		testutil.CreateTestFile(filepath.Join(tmpDir, dir, "b.txt"), "second group, a bit larger")
+	}
+
+	audit, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit.Close() // every write now fails
+
+	files, _ := scanner.Walk(tmpDir, false)
+	plan := NewPlan(scanner.New().ScanFiles(files, false), StrategyPolicy(KeepAlphabetical), []string{tmpDir}, nil)
+	summary := Execute(plan, Delete{}, Options{Audit: audit})
+	if summary.Failed != 0 || summary.Skipped != 2 || summary.Files != 0 {
+		t.Errorf("Expected nothing done without an intent entry, got %+v", summary)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, "y", name)); err != nil {
+			t.Errorf("Expected y/%s to be left alone: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package dedupe
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Action removes or replaces a duplicate whose content matches the kept file
+type Action interface {
+	// Name describes the action in reports, e.g. "delete"
+	Name() string
+
+	// Apply acts on dup, returning how many bytes were actually freed
+	Apply(keep, dup string, size int64) (int64, error)
+}
+
+// Estimator is implemented by actions whose savings can differ from the
+// file size, so dry runs report what would really be freed
+type Estimator interface {
+	Estimate(keep, dup string, size int64) (int64, error)
+}
+
+// Mover is implemented by actions that move duplicates elsewhere instead of
+// removing them. Moving frees nothing until the moved files are deleted, so
+// their sizes are reported as moved rather than reclaimed.
+type Mover interface {
+	MovesFiles()
+}
+
+// Delete removes duplicates outright
+type Delete struct{}
+
+func (Delete) Name() string {
+	return "delete"
+}
+
+func (d Delete) Apply(keep, dup string, size int64) (int64, error) {
+	freed, err := d.Estimate(keep, dup, size)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Remove(dup); err != nil {
+		return 0, err
+	}
+	return freed, nil
+}
+
+// Estimate checks that dup is not the kept file itself and returns the bytes
+// deleting it would really free
+func (Delete) Estimate(keep, dup string, size int64) (int64, error) {
+	dupInfo, err := distinctFile(keep, dup)
+	if err != nil {
+		return 0, err
+	}
+
+	// The data is only freed if no other path still links to it
+	if linkCount(dupInfo) > 1 {
+		return 0, nil
+	}
+	return size, nil
+}
+
+// distinctFile returns dup's metadata, or skips it when dup is the kept file
+// under another name: reached through a symlinked directory or bind mount,
+// or hard linked to it. Removing or moving it could then take the kept
+// copy's only data with it.
+func distinctFile(keep, dup string) (os.FileInfo, error) {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return nil, err
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return nil, err
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return nil, Skip("same file as the kept copy")
+	}
+	return dupInfo, nil
+}
+
+// pendingLinks counts the hard links to each inode that a dry run has already
+// removed. Nothing touches the disk, so every link still looks shared; the
+// real run frees the data when the last of them goes, and so does this.
+type pendingLinks map[[2]uint64]uint64
+
+// release records that dup's link would be removed and returns size if that
+// was the last remaining link to its data
+func (p pendingLinks) release(dup string, size int64) int64 {
+	info, err := os.Lstat(dup)
+	if err != nil || linkCount(info) < 2 {
+		return 0
+	}
+	dev, ino, ok := scanner.FileID(info)
+	if !ok {
+		return 0
+	}
+
+	key := [2]uint64{dev, ino}
+	p[key]++
+	if p[key] == linkCount(info) {
+		return size
+	}
+	return 0
+}
+
+// SkipError is returned by actions that deliberately left a file alone
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return "skipped: " + e.Reason
+}
+
+// Skip builds a SkipError
+func Skip(reason string) error {
+	return &SkipError{Reason: reason}
+}
+
+// Result records what happened to a single duplicate
+type Result struct {
+	Keep    string
+	Reason  string
+	Path    string
+	Bytes   int64
+	Err     error
+	Skipped bool
+
+	// Moved is the size of a duplicate a Mover put out of the way
+	Moved int64
+}
+
+// Summary totals a dedupe run
+type Summary struct {
+	Action         string
+	DryRun         bool
+	Groups         int
+	Files          int
+	BytesReclaimed int64
+	BytesMoved     int64
+	Skipped        int
+	Informational  []InfoGroup
+	Failed         int
+	Results        []Result
+}
+
+// Options controls how a plan is executed
+type Options struct {
+	// DryRun reports what would happen without touching any file
+	DryRun bool
+	// Protect guards files a plan should never have offered for removal,
+	// e.g. after an interactive review changed which copies are kept
+	Protect *Protector
+	// Audit, when set, receives an entry for every operation performed
+	Audit *AuditLog
+}
+
+// Execute applies action to every duplicate in the plan
+func Execute(plan Plan, action Action, opts Options) Summary {
+	summary := Summary{Action: action.Name(), DryRun: opts.DryRun, Informational: plan.Informational}
+	_, moves := action.(Mover)
+	links := make(pendingLinks)
+
+	for _, gp := range plan.Groups {
+		summary.Groups++
+
+		var digest string
+		var groupErr error
+		if opts.Audit.Failed() != nil {
+			groupErr = Skip("stopped after the audit log could not be written")
+		} else if !opts.DryRun {
+			// Files may have changed since the scan; never act on stale groups
+			groupErr = validateGroup(gp)
+			if groupErr == nil && opts.Audit != nil {
+				var err error
+				if digest, err = fileDigest(gp.Keep); err != nil {
+					groupErr = Skip(fmt.Sprintf("cannot digest kept file for the audit log: %v", err))
+				}
+			}
+		}
+		if groupErr != nil {
+			for _, dup := range gp.Remove {
+				result := Result{Keep: gp.Keep, Reason: gp.Reason, Path: dup, Err: groupErr}
+				tally(&summary, &result, 0)
+				summary.Results = append(summary.Results, result)
+			}
+			continue
+		}
+
+		for _, dup := range gp.Remove {
+			result := Result{Keep: gp.Keep, Reason: gp.Reason, Path: dup}
+			if opts.Protect.Protected(dup) {
+				result.Err = Skip("protected")
+			} else if opts.DryRun {
+				result.Bytes = gp.Group.Size
+				if e, ok := action.(Estimator); ok {
+					result.Bytes, result.Err = e.Estimate(gp.Keep, dup, gp.Group.Size)
+					if result.Err == nil && result.Bytes == 0 && !moves {
+						result.Bytes = links.release(dup, gp.Group.Size)
+					}
+				}
+			} else {
+				// Never go on with operations that cannot be accounted for
+				result.Bytes, result.Err = opts.Audit.perform(planEntry(action.Name(), gp, dup, digest), func() (int64, error) {
+					return action.Apply(gp.Keep, dup, gp.Group.Size)
+				})
+			}
+
+			if tally(&summary, &result, result.Bytes) && moves {
+				result.Moved = gp.Group.Size
+				summary.BytesMoved += gp.Group.Size
+			}
+			summary.Results = append(summary.Results, result)
+		}
+	}
+	return summary
+}
+
+// tally adds a result to the summary and reports whether it succeeded
+func tally(summary *Summary, result *Result, size int64) bool {
+	var skip *SkipError
+	switch {
+	case errors.As(result.Err, &skip):
+		result.Skipped = true
+		summary.Skipped++
+	case result.Err != nil:
+		summary.Failed++
+	default:
+		result.Bytes = size
+		summary.Files++
+		summary.BytesReclaimed += size
+		return true
+	}
+	return false
+}
+
+// countKeeps returns how many distinct kept files the results refer to
+func countKeeps(results []Result) int {
+	keeps := make(map[string]bool)
+	for _, r := range results {
+		keeps[r.Keep] = true
+	}
+	return len(keeps)
+}
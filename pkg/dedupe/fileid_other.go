@@ -0,0 +1,20 @@
+//go:build !unix
+
+package dedupe
+
+import (
+	"errors"
+	"os"
+)
+
+// linkCount assumes a single link when the platform does not report one
+func linkCount(info os.FileInfo) uint64 {
+	return 1
+}
+
+// crossDevice treats every failed rename as a possible cross-volume move;
+// the copy fallback verifies the data before removing the source
+func crossDevice(err error) bool {
+	var linkErr *os.LinkError
+	return errors.As(err, &linkErr)
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package dedupe
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// linkCount returns how many directory entries point at the file's data
+func linkCount(info os.FileInfo) uint64 {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+	return uint64(st.Nlink)
+}
+
+// crossDevice reports whether a rename failed because source and target are
+// on different filesystems
+func crossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
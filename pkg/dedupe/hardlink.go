@@ -0,0 +1,92 @@
+package dedupe
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HardLink replaces duplicates with hard links to the kept file, so every
+// path survives but the data is stored once
+type HardLink struct{}
+
+func (HardLink) Name() string {
+	return "hard link"
+}
+
+func (h HardLink) Apply(keep, dup string, size int64) (int64, error) {
+	freed, err := h.Estimate(keep, dup, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := replaceWith(dup, func(tmp string) error {
+		return os.Link(keep, tmp)
+	}); err != nil {
+		return 0, err
+	}
+	return freed, nil
+}
+
+// Estimate checks that dup can be linked and returns the bytes that linking
+// would really free
+func (HardLink) Estimate(keep, dup string, size int64) (int64, error) {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return 0, err
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return 0, err
+	}
+
+	if os.SameFile(keepInfo, dupInfo) {
+		return 0, Skip("already hard linked to kept file")
+	}
+	if !sameDevice(keep, dup, keepInfo, dupInfo) {
+		return 0, Skip("on a different filesystem than the kept file, hard link impossible")
+	}
+
+	// The data is only freed if no other path still links to it
+	if linkCount(dupInfo) > 1 {
+		return 0, nil
+	}
+	return size, nil
+}
+
+// sameDevice reports whether two files live on the same filesystem
+func sameDevice(a, b string, aInfo, bInfo os.FileInfo) bool {
+	aDev, ok1 := deviceID(aInfo)
+	bDev, ok2 := deviceID(bInfo)
+	if ok1 && ok2 {
+		return aDev == bDev
+	}
+
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && filepath.VolumeName(absA) == filepath.VolumeName(absB)
+}
+
+// deviceID returns the filesystem a file lives on
+func deviceID(info os.FileInfo) (uint64, bool) {
+	dev, _, ok := scanner.FileID(info)
+	return dev, ok
+}
+
+// replaceWith creates a replacement next to path through create, then
+// renames it over path. The rename is atomic, so a crash leaves either the
+// original file or its replacement, never neither.
+func replaceWith(path string, create func(tmp string) error) error {
+	tmp := filepath.Join(filepath.Dir(path), fmt.Sprintf(".%s.dupe-%d.tmp", filepath.Base(path), os.Getpid()))
+	os.Remove(tmp) // stale leftover from an earlier crash
+
+	if err := create(tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package dedupe
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Strategy decides which file of a duplicate group is kept
+type Strategy string
+
+const (
+	KeepOldest       Strategy = "oldest"
+	KeepNewest       Strategy = "newest"
+	KeepShortestPath Strategy = "shortest-path"
+	KeepLongestPath  Strategy = "longest-path"
+	KeepFirstRoot    Strategy = "first-root"
+	KeepAlphabetical Strategy = "alphabetical"
+)
+
+// Strategies lists every keep strategy in the order they are documented
+var Strategies = []Strategy{
+	KeepOldest, KeepNewest, KeepShortestPath, KeepLongestPath, KeepFirstRoot, KeepAlphabetical,
+}
+
+// ParseStrategy validates a strategy name given on the command line
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if string(s) == name {
+			return s, nil
+		}
+	}
+
+	var names []string
+	for _, s := range Strategies {
+		names = append(names, string(s))
+	}
+	return "", fmt.Errorf("unknown keep strategy %q (want one of %s)", name, strings.Join(names, ", "))
+}
+
+// candidate is a file the strategy can choose from
+type candidate struct {
+	Path    string
+	ModTime int64
+}
+
+// compare orders two candidates by the strategy alone: negative when a
+// should be kept over b, zero when the strategy cannot tell them apart
+func (s Strategy) compare(a, b candidate, roots []string) int {
+	switch s {
+	case KeepOldest:
+		return cmp.Compare(a.ModTime, b.ModTime)
+	case KeepNewest:
+		return cmp.Compare(b.ModTime, a.ModTime)
+	case KeepShortestPath:
+		return cmp.Compare(len(a.Path), len(b.Path))
+	case KeepLongestPath:
+		return cmp.Compare(len(b.Path), len(a.Path))
+	case KeepFirstRoot:
+		return cmp.Compare(rootIndex(a.Path, roots), rootIndex(b.Path, roots))
+	case KeepAlphabetical:
+		return strings.Compare(a.Path, b.Path)
+	}
+	return 0
+}
+
+// rootIndex returns the position of the first root containing path, or
+// len(roots) when none does
+func rootIndex(path string, roots []string) int {
+	for i, root := range roots {
+		if isWithin(path, root) {
+			return i
+		}
+	}
+	return len(roots)
+}
+
+// isWithin reports whether path is root or lies below it
+func isWithin(path, root string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
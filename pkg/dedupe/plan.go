@@ -0,0 +1,127 @@
+package dedupe
+
+import (
+	"dupe-file-checker/pkg/archive"
+	"dupe-file-checker/pkg/scanner"
+	"os"
+	"sort"
+)
+
+// GroupPlan says which file of a duplicate group survives and which go
+type GroupPlan struct {
+	Group  scanner.DuplicateGroup
+	Keep   string
+	Remove []string
+	// Reason explains which policy rule picked Keep
+	Reason string
+}
+
+// InfoGroup is a duplicate group that is reported but never acted on
+type InfoGroup struct {
+	Group  scanner.DuplicateGroup
+	Reason string
+}
+
+// Plan is the set of actions a dedupe run intends to take
+type Plan struct {
+	Groups []GroupPlan
+	// Informational lists groups that are reported but never acted on,
+	// such as those whose files are all protected
+	Informational []InfoGroup
+}
+
+// NewPlan picks a file to keep in every byte-identical group using policy.
+// Groups found by similarity modes, and archive members (which cannot be
+// modified in place), are never acted on. Protected files are never
+// removed: when a group has any, the file kept is chosen among them.
+func NewPlan(groups []scanner.DuplicateGroup, policy *Policy, roots []string, protect *Protector) Plan {
+	var plan Plan
+	// Trashed files may be deleted for good at any time, so they are
+	// neither kept nor acted on, whatever the action
+	trash, _ := NewTrash()
+	for _, group := range groups {
+		if group.Kind != scanner.MatchExact && group.Kind != "" {
+			continue
+		}
+
+		var files []candidate
+		for i, path := range group.Files {
+			if _, _, virtual := archive.Split(path); virtual || trash.holds(path) {
+				continue
+			}
+			files = append(files, candidate{Path: path, ModTime: modTime(group, i)})
+		}
+		if len(files) < 2 {
+			continue
+		}
+
+		var protected, unprotected []candidate
+		for _, f := range files {
+			if protect.Protected(f.Path) {
+				protected = append(protected, f)
+			} else {
+				unprotected = append(unprotected, f)
+			}
+		}
+		if len(unprotected) == 0 {
+			plan.Informational = append(plan.Informational, InfoGroup{Group: group, Reason: "all copies are protected"})
+			continue
+		}
+
+		pool := files
+		if len(protected) > 0 {
+			pool = protected
+		}
+		keep, reason, ok := policy.choose(pool, roots)
+		if !ok {
+			plan.Informational = append(plan.Informational, InfoGroup{Group: group, Reason: reason})
+			continue
+		}
+
+		gp := GroupPlan{Group: group, Keep: pool[keep].Path, Reason: reason}
+		if len(protected) > 0 {
+			gp.Reason = "protected, " + reason
+		} else {
+			unprotected = append(files[:keep:keep], files[keep+1:]...)
+		}
+		for _, f := range unprotected {
+			gp.Remove = append(gp.Remove, f.Path)
+		}
+		sort.Strings(gp.Remove)
+		plan.Groups = append(plan.Groups, gp)
+	}
+
+	// Largest savings first, so an interrupted run has done the most good
+	sort.SliceStable(plan.Groups, func(i, j int) bool {
+		a, b := plan.Groups[i], plan.Groups[j]
+		wa, wb := a.Group.Size*int64(len(a.Remove)), b.Group.Size*int64(len(b.Remove))
+		if wa != wb {
+			return wa > wb
+		}
+		return a.Keep < b.Keep
+	})
+	return plan
+}
+
+// modTime returns the scan-time mtime of the i-th file, or stats it if the
+// group carries no metadata
+func modTime(group scanner.DuplicateGroup, i int) int64 {
+	if i < len(group.Infos) && group.Infos[i].Path == group.Files[i] {
+		return group.Infos[i].ModTime
+	}
+	info, err := os.Stat(group.Files[i])
+	if err != nil {
+		return 0
+	}
+	return info.ModTime().Unix()
+}
+
+// ScanModTime returns the mtime path had when the group was found
+func ScanModTime(group scanner.DuplicateGroup, path string) int64 {
+	for i, f := range group.Files {
+		if f == path {
+			return modTime(group, i)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,192 @@
+package dedupe
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// Rule is one step of a keep policy. Exactly one of Prefer, Avoid or Never
+// is set:
+//
+//	{"prefer": "oldest"}                   any keep strategy
+//	{"prefer": "path", "match": "/archive/"} copies whose path matches
+//	{"avoid": "name", "match": "copy|\\(1\\)"} copies whose name does not match
+//	{"never": "path", "match": "^/tmp/"}   copies that may never be kept
+//
+// Match is a regular expression tested against the full path or the file name.
+type Rule struct {
+	Prefer string `json:"prefer,omitempty"`
+	Avoid  string `json:"avoid,omitempty"`
+	Never  string `json:"never,omitempty"`
+	Match  string `json:"match,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Policy is an ordered list of rules; each rule only breaks the ties left
+// by the ones before it
+type Policy struct {
+	Rules []Rule `json:"rules"`
+}
+
+// StrategyPolicy wraps a single keep strategy as a policy
+func StrategyPolicy(s Strategy) *Policy {
+	return &Policy{Rules: []Rule{{Prefer: string(s)}}}
+}
+
+// LoadPolicy reads and validates a JSON policy file
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy decodes and validates a JSON policy
+func ParsePolicy(data []byte) (*Policy, error) {
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid policy: %v", err)
+	}
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("invalid policy: no rules")
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+		set := 0
+		for _, v := range []string{r.Prefer, r.Avoid, r.Never} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("policy rule %d: set exactly one of prefer, avoid or never", i+1)
+		}
+
+		if r.Prefer != "" && r.Match == "" {
+			if _, err := ParseStrategy(r.Prefer); err != nil {
+				return nil, fmt.Errorf("policy rule %d: %v", i+1, err)
+			}
+			continue
+		}
+
+		if target := r.target(); target != "path" && target != "name" {
+			return nil, fmt.Errorf("policy rule %d: %q needs to be path or name when matching", i+1, target)
+		}
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("policy rule %d: %v", i+1, err)
+		}
+		r.re = re
+	}
+	return &p, nil
+}
+
+// target is what a matching rule tests: "path" or "name"
+func (r Rule) target() string {
+	switch {
+	case r.Prefer != "":
+		return r.Prefer
+	case r.Avoid != "":
+		return r.Avoid
+	}
+	return r.Never
+}
+
+// matches tests a file name, or its absolute slash-separated path, so rules
+// do not depend on how the roots were typed on the command line
+func (r Rule) matches(path string) bool {
+	if r.target() == "name" {
+		return r.re.MatchString(filepath.Base(path))
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return r.re.MatchString(filepath.ToSlash(path))
+}
+
+func (r Rule) String() string {
+	switch {
+	case r.Prefer != "" && r.re == nil:
+		return "prefer " + r.Prefer
+	case r.Prefer != "":
+		return fmt.Sprintf("prefer %s matching %s", r.Prefer, r.Match)
+	case r.Avoid != "":
+		return fmt.Sprintf("avoid %s matching %s", r.Avoid, r.Match)
+	}
+	return fmt.Sprintf("never keep %s matching %s", r.Never, r.Match)
+}
+
+// choose applies the rules in order and returns the index of the file to
+// keep with the rule that settled it. ok is false when a never rule leaves
+// no copy that may be kept.
+func (p *Policy) choose(files []candidate, roots []string) (keep int, reason string, ok bool) {
+	remaining := make([]int, len(files))
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	explain := func(i int, r Rule) string {
+		if len(p.Rules) == 1 {
+			return r.String()
+		}
+		return fmt.Sprintf("rule %d: %s", i+1, r)
+	}
+
+	for i, r := range p.Rules {
+		switch {
+		case r.Never != "":
+			remaining = filterFiles(files, remaining, func(c candidate) bool { return !r.matches(c.Path) })
+			if len(remaining) == 0 {
+				return -1, explain(i, r) + " rules out every copy", false
+			}
+		case r.Avoid != "":
+			if kept := filterFiles(files, remaining, func(c candidate) bool { return !r.matches(c.Path) }); len(kept) > 0 {
+				remaining = kept
+			}
+		case r.re != nil:
+			if kept := filterFiles(files, remaining, func(c candidate) bool { return r.matches(c.Path) }); len(kept) > 0 {
+				remaining = kept
+			}
+		default:
+			s := Strategy(r.Prefer)
+			best := remaining[0]
+			for _, idx := range remaining[1:] {
+				if s.compare(files[idx], files[best], roots) < 0 {
+					best = idx
+				}
+			}
+			remaining = filterFiles(files, remaining, func(c candidate) bool { return s.compare(c, files[best], roots) == 0 })
+		}
+
+		if len(remaining) == 1 {
+			return remaining[0], explain(i, r), true
+		}
+	}
+
+	// Still tied: fall back to alphabetical order so the choice never
+	// depends on scan order
+	best := remaining[0]
+	for _, idx := range remaining[1:] {
+		if files[idx].Path < files[best].Path {
+			best = idx
+		}
+	}
+	return best, "alphabetical tie-break", true
+}
+
+// filterFiles returns the indexes in remaining whose file satisfies keep
+func filterFiles(files []candidate, remaining []int, keep func(candidate) bool) []int {
+	var out []int
+	for _, idx := range remaining {
+		if keep(files[idx]) {
+			out = append(out, idx)
+		}
+	}
+	return out
+}
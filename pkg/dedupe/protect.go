@@ -0,0 +1,98 @@
+package dedupe
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Protector decides which files no dedupe action may ever touch. Protected
+// files can still be the kept original of a group.
+type Protector struct {
+	patterns []string
+}
+
+// NewProtector compiles protection patterns. A pattern is either a path
+// (the file or directory and everything below it) or a glob: globs without
+// a slash, like "*.keep", match file names anywhere; globs with one, like
+// "/data/master/**", match whole paths, with "**" spanning directories.
+func NewProtector(patterns []string) (*Protector, error) {
+	p := &Protector{}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if strings.ContainsRune(pattern, '/') || strings.ContainsRune(pattern, filepath.Separator) {
+			abs, err := filepath.Abs(pattern)
+			if err != nil {
+				return nil, err
+			}
+			pattern = abs
+		}
+		pattern = filepath.ToSlash(pattern)
+
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid protect pattern %q: %v", pattern, err)
+			}
+		}
+		p.patterns = append(p.patterns, pattern)
+	}
+	return p, nil
+}
+
+// Protected reports whether file matches any pattern. A nil Protector
+// protects nothing.
+func (p *Protector) Protected(file string) bool {
+	if p == nil || len(p.patterns) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return true // err on the side of leaving it alone
+	}
+	abs = filepath.ToSlash(abs)
+
+	for _, pattern := range p.patterns {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(abs)); ok {
+				return true
+			}
+			continue
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			if isWithin(filepath.FromSlash(abs), filepath.FromSlash(pattern)) {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(pattern, "/"), strings.Split(abs, "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" segment matches any number of path segments
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
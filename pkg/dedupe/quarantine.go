@@ -0,0 +1,378 @@
+package dedupe
+
+import (
+	"dupe-file-checker/pkg/hasher"
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QuarantineRecord is one journal entry: a duplicate moved out of the way
+type QuarantineRecord struct {
+	Original    string    `json:"original"`
+	Quarantined string    `json:"quarantined"`
+	Keep        string    `json:"keep"`
+	Size        int64     `json:"size"`
+	MovedAt     time.Time `json:"moved_at"`
+}
+
+// Quarantine moves duplicates into a tree under Dir that mirrors their
+// original absolute paths, journaling every move so it can be undone
+type Quarantine struct {
+	Dir     string
+	journal *Recorder
+}
+
+// quarantine layout: the mirrored tree and the journal side by side. The
+// scanner recognises it and never walks a quarantine.
+const (
+	quarantineFiles   = scanner.QuarantineFiles
+	quarantineJournal = scanner.QuarantineJournal
+)
+
+// OpenQuarantine creates dir if needed and opens its journal for appending
+func OpenQuarantine(dir string) (*Quarantine, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(abs, quarantineFiles), 0755); err != nil {
+		return nil, err
+	}
+	journal, err := CreateRecorder(filepath.Join(abs, quarantineJournal))
+	if err != nil {
+		return nil, err
+	}
+	return &Quarantine{Dir: abs, journal: journal}, nil
+}
+
+func (q *Quarantine) Close() error {
+	return q.journal.Close()
+}
+
+func (q *Quarantine) Name() string {
+	return "quarantine"
+}
+
+func (*Quarantine) MovesFiles() {}
+
+func (q *Quarantine) Apply(keep, dup string, size int64) (int64, error) {
+	if _, err := q.Estimate(keep, dup, size); err != nil {
+		return 0, err
+	}
+	absDup, err := filepath.Abs(dup)
+	if err != nil {
+		return 0, err
+	}
+	absKeep, err := filepath.Abs(keep)
+	if err != nil {
+		return 0, err
+	}
+
+	dest, err := freePath(q.mirrorPath(absDup))
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+
+	// Journal first, so an interrupted move can still be found and restored
+	rec := QuarantineRecord{Original: absDup, Quarantined: dest, Keep: absKeep, Size: size, MovedAt: time.Now()}
+	if err := q.journal.Add(rec); err != nil {
+		return 0, err
+	}
+	if err := moveFile(absDup, dest); err != nil {
+		return 0, err
+	}
+	// Nothing is freed until the quarantine is purged
+	return 0, nil
+}
+
+// Estimate checks that dup can be quarantined. Quarantining frees nothing.
+func (q *Quarantine) Estimate(keep, dup string, size int64) (int64, error) {
+	absDup, err := filepath.Abs(dup)
+	if err != nil {
+		return 0, err
+	}
+	absKeep, err := filepath.Abs(keep)
+	if err != nil {
+		return 0, err
+	}
+	dir, err := filepath.Abs(q.Dir)
+	if err != nil {
+		return 0, err
+	}
+
+	if isWithin(absDup, dir) {
+		return 0, Skip("already inside the quarantine directory")
+	}
+	// A quarantined copy may be purged later, so it can never be the one kept
+	if isWithin(absKeep, dir) {
+		return 0, Skip("kept file is inside the quarantine directory")
+	}
+	if _, err := distinctFile(keep, dup); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// mirrorPath maps an absolute path into the quarantine tree. Windows volume
+// names become a directory of their own (C: -> C).
+func (q *Quarantine) mirrorPath(abs string) string {
+	vol := filepath.VolumeName(abs)
+	rest := strings.TrimPrefix(abs, vol)
+	vol = strings.NewReplacer(":", "", `\`, "_", "/", "_").Replace(vol)
+	return filepath.Join(q.Dir, quarantineFiles, vol, rest)
+}
+
+// freePath returns path, or path with a numeric suffix if an earlier run
+// already quarantined a file there
+func freePath(path string) (string, error) {
+	candidate := path
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+		candidate = path + "." + strconv.Itoa(i)
+	}
+}
+
+// moveFile renames src to dst, falling back to copy, verify and unlink when
+// they are on different filesystems
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !crossDevice(err) {
+		return err
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(src, dst, info.Mode().Perm()); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	if err := os.Chtimes(dst, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	srcHash, err := hasher.ComputeFullHash(src)
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	dstHash, err := hasher.ComputeFullHash(dst)
+	if err != nil || dstHash != srcHash {
+		os.Remove(dst)
+		return fmt.Errorf("copy of %s did not verify", src)
+	}
+	return os.Remove(src)
+}
+
+// RestoreQuarantine replays the journal in dir backwards, moving every
+// quarantined file back to its original path. Entries that cannot be
+// restored stay in the journal. audit, when set, logs every restore.
+func RestoreQuarantine(dir string, audit *AuditLog) (Summary, error) {
+	records, err := readRecords[QuarantineRecord](filepath.Join(dir, quarantineJournal))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary := Summary{Action: "restore"}
+	restored := make([]bool, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		rec := records[i]
+		result := Result{Keep: rec.Keep, Path: rec.Original}
+		_, result.Err = audit.perform(rec.entry("restore", ""), func() (int64, error) {
+			return 0, restoreOne(rec)
+		})
+		restored[i] = tally(&summary, &result, rec.Size)
+		summary.Results = append(summary.Results, result)
+	}
+	// Restoring frees nothing
+	summary.BytesReclaimed = 0
+	summary.Groups = countKeeps(summary.Results)
+
+	return summary, rewriteJournal(dir, records, restored)
+}
+
+// entry describes an operation on a quarantined file for the audit log
+func (rec QuarantineRecord) entry(action, digest string) AuditEntry {
+	return AuditEntry{
+		Action:      action,
+		Source:      rec.Original,
+		Target:      rec.Keep,
+		Quarantined: rec.Quarantined,
+		Size:        rec.Size,
+		Digest:      digest,
+	}
+}
+
+func restoreOne(rec QuarantineRecord) error {
+	if _, err := os.Lstat(rec.Quarantined); os.IsNotExist(err) {
+		return Skip("no longer in quarantine")
+	}
+	if _, err := os.Lstat(rec.Original); err == nil {
+		return Skip("original path is occupied")
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.Original), 0755); err != nil {
+		return err
+	}
+	return moveFile(rec.Quarantined, rec.Original)
+}
+
+// PurgeQuarantine permanently deletes quarantined files moved before
+// now minus olderThan and drops them from the journal. A file is only
+// deleted while its kept copy still exists with the same content. audit, when
+// set, logs every deletion.
+func PurgeQuarantine(dir string, olderThan time.Duration, now time.Time, audit *AuditLog) (Summary, error) {
+	records, err := readRecords[QuarantineRecord](filepath.Join(dir, quarantineJournal))
+	if err != nil {
+		return Summary{}, err
+	}
+	filesDir, err := filepath.Abs(filepath.Join(dir, quarantineFiles))
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary := Summary{Action: "purge"}
+	purged := make([]bool, len(records))
+	cutoff := now.Add(-olderThan)
+	for i, rec := range records {
+		if rec.MovedAt.After(cutoff) {
+			continue
+		}
+
+		result := Result{Keep: rec.Keep, Path: rec.Quarantined}
+		var digest string
+		if audit != nil {
+			// A missing kept file leaves no digest; purgeOne skips the entry
+			digest, _ = fileDigest(rec.Keep)
+		}
+		var freed int64
+		freed, result.Err = audit.perform(rec.entry("purge", digest), func() (int64, error) {
+			return purgeOne(rec, filesDir)
+		})
+		purged[i] = tally(&summary, &result, freed)
+		if purged[i] {
+			pruneEmptyDirs(filepath.Dir(rec.Quarantined), filesDir)
+		}
+		summary.Results = append(summary.Results, result)
+	}
+	summary.Groups = countKeeps(summary.Results)
+
+	return summary, rewriteJournal(dir, records, purged)
+}
+
+// purgeOne deletes a quarantined file and returns the bytes freed. The
+// journal is not trusted: entries pointing outside the quarantine tree, or
+// whose kept copy is gone or has changed, are skipped.
+func purgeOne(rec QuarantineRecord, filesDir string) (int64, error) {
+	path := filepath.Clean(rec.Quarantined)
+	if !filepath.IsAbs(path) || path == filesDir || !isWithin(path, filesDir) {
+		return 0, Skip("not inside the quarantine directory")
+	}
+	// A symlinked directory inside the tree could lead anywhere
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if os.IsNotExist(err) {
+		// Already gone, the journal entry is simply stale
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	realFiles, err := filepath.EvalSymlinks(filesDir)
+	if err != nil {
+		return 0, err
+	}
+	if !isWithin(realDir, realFiles) {
+		return 0, Skip("not inside the quarantine directory")
+	}
+	if _, err := os.Lstat(path); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// The quarantined file may be the last copy by now
+	if _, err := distinctFile(rec.Keep, path); err != nil {
+		if os.IsNotExist(err) {
+			return 0, Skip("kept file " + rec.Keep + " no longer exists")
+		}
+		return 0, err
+	}
+	same, err := sameContent(rec.Keep, path)
+	if err != nil {
+		return 0, err
+	}
+	if !same {
+		return 0, Skip("kept file " + rec.Keep + " no longer matches")
+	}
+
+	if err := os.Remove(path); err != nil {
+		return 0, err
+	}
+	return rec.Size, nil
+}
+
+// rewriteJournal atomically replaces the journal with the entries not done
+func rewriteJournal(dir string, records []QuarantineRecord, done []bool) error {
+	path := filepath.Join(dir, quarantineJournal)
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	journal, err := CreateRecorder(tmp)
+	if err != nil {
+		return err
+	}
+	for i, rec := range records {
+		if done[i] {
+			continue
+		}
+		if err := journal.Add(rec); err != nil {
+			journal.Close()
+			return err
+		}
+	}
+	if err := journal.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty, stopping at stop
+func pruneEmptyDirs(dir, stop string) {
+	for isWithin(dir, stop) && dir != stop {
+		if os.Remove(dir) != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+// ParseAge parses durations like "30d", "12h" or "2w"
+func ParseAge(s string) (time.Duration, error) {
+	units := map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour}
+	for suffix, unit := range units {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			v, err := strconv.ParseFloat(n, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid age %q", s)
+			}
+			return time.Duration(v * float64(unit)), nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
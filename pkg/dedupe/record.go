@@ -0,0 +1,67 @@
+package dedupe
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Recorder appends one JSON object per line to a record file, so a crash
+// mid-run still leaves every completed step on disk
+type Recorder struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// CreateRecorder opens path for appending, creating it if needed
+func CreateRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{file: f}, nil
+}
+
+// Add writes v as a single line and syncs it to disk
+func (r *Recorder) Add(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return r.file.Sync()
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// readRecords decodes every line of a record file into a T
+func readRecords[T any](path string) ([]T, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []T
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec T
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
@@ -0,0 +1,46 @@
+package dedupe
+
+import (
+	"os"
+)
+
+// Reflink makes duplicates share the kept file's extents (copy-on-write
+// clones). Unlike hard links the paths stay independently modifiable.
+// Filesystems without reflink support leave the duplicate untouched and the
+// file is reported as skipped.
+type Reflink struct{}
+
+func (Reflink) Name() string {
+	return "reflink"
+}
+
+func (r Reflink) Apply(keep, dup string, size int64) (int64, error) {
+	if _, err := r.Estimate(keep, dup, size); err != nil {
+		return 0, err
+	}
+	return reflink(keep, dup, size)
+}
+
+// Estimate rules out files that can never be cloned; whether the filesystem
+// supports reflinks is only known once the clone is attempted
+func (Reflink) Estimate(keep, dup string, size int64) (int64, error) {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return 0, err
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return 0, err
+	}
+
+	if !dupInfo.Mode().IsRegular() {
+		return 0, Skip("not a regular file")
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return 0, Skip("hard linked to kept file, nothing to share")
+	}
+	if !sameDevice(keep, dup, keepInfo, dupInfo) {
+		return 0, Skip("on a different filesystem than the kept file, reflink impossible")
+	}
+	return size, nil
+}
@@ -0,0 +1,176 @@
+package dedupe
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// ioctl numbers from linux/fs.h
+const (
+	ficlone       = 0x40049409
+	fideduperange = 0xc0189436
+)
+
+// dedupeChunk bounds a single FIDEDUPERANGE call; some filesystems cap the
+// length they accept per request
+const dedupeChunk = 16 << 20
+
+// dedupeRange mirrors struct file_dedupe_range with a single
+// file_dedupe_range_info entry
+type dedupeRange struct {
+	srcOffset    uint64
+	srcLength    uint64
+	destCount    uint16
+	reserved1    uint16
+	reserved2    uint32
+	destFd       int64
+	destOffset   uint64
+	bytesDeduped uint64
+	status       int32
+	reserved     uint32
+}
+
+// file_dedupe_range_info status values
+const (
+	dedupeSame    = 0
+	dedupeDiffers = 1
+)
+
+// reflink first asks the kernel to dedupe dup against keep in place, which
+// verifies the contents match and leaves dup's metadata alone. Filesystems
+// that only implement FICLONE get a fresh clone renamed over dup, carrying
+// over its permissions, ownership and timestamps.
+func reflink(keep, dup string, size int64) (int64, error) {
+	freed, err := dedupeInPlace(keep, dup, size)
+	if err == nil {
+		return freed, nil
+	}
+	if !unsupported(err) {
+		return 0, err
+	}
+
+	err = cloneOver(keep, dup)
+	if err == nil {
+		return size, nil
+	}
+	if unsupported(err) {
+		return 0, Skip("filesystem does not support reflinks, file left unchanged")
+	}
+	return 0, err
+}
+
+func dedupeInPlace(keep, dup string, size int64) (int64, error) {
+	src, err := os.Open(keep)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dup, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	var total int64
+	for total < size {
+		length := size - total
+		if length > dedupeChunk {
+			length = dedupeChunk
+		}
+
+		req := dedupeRange{
+			srcOffset:  uint64(total),
+			srcLength:  uint64(length),
+			destCount:  1,
+			destFd:     int64(dst.Fd()),
+			destOffset: uint64(total),
+		}
+		if err := ioctl(src.Fd(), fideduperange, unsafe.Pointer(&req)); err != nil {
+			return total, err
+		}
+		switch {
+		case req.status == dedupeDiffers:
+			return total, errors.New("contents changed since the scan")
+		case req.status < 0:
+			return total, syscall.Errno(-req.status)
+		case req.bytesDeduped == 0:
+			return total, errors.New("kernel deduped no bytes")
+		}
+		total += int64(req.bytesDeduped)
+	}
+	return total, nil
+}
+
+func cloneOver(keep, dup string) error {
+	info, err := os.Lstat(dup)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(keep)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return replaceWith(dup, func(tmp string) error {
+		dst, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+
+		err = ioctlInt(dst.Fd(), ficlone, src.Fd())
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = copyMetadata(tmp, info)
+		}
+		if err != nil {
+			os.Remove(tmp)
+		}
+		return err
+	})
+}
+
+// copyMetadata gives the clone the mode, owner and times of the file it replaces
+func copyMetadata(path string, info os.FileInfo) error {
+	if err := os.Chmod(path, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	atime := info.ModTime()
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		atime = time.Unix(st.Atim.Unix())
+		// Only root can give files away; other users already own the clone
+		if err := os.Lchown(path, int(st.Uid), int(st.Gid)); err != nil && os.Geteuid() == 0 {
+			return err
+		}
+	}
+	return os.Chtimes(path, atime, info.ModTime())
+}
+
+func ioctl(fd uintptr, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func ioctlInt(fd uintptr, req uintptr, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// unsupported reports whether err means the filesystem cannot share extents
+func unsupported(err error) bool {
+	return errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOTTY) ||
+		errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.EXDEV) ||
+		errors.Is(err, syscall.ENOSYS)
+}
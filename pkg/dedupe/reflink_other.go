@@ -0,0 +1,8 @@
+//go:build !linux
+
+package dedupe
+
+// reflink is only implemented with the Linux clone ioctls
+func reflink(keep, dup string, size int64) (int64, error) {
+	return 0, Skip("reflinks are not supported on this platform, file left unchanged")
+}
@@ -0,0 +1,140 @@
+package dedupe
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScriptOptions controls what an emitted script does with each duplicate
+type ScriptOptions struct {
+	// Link is "" to remove duplicates, or "hard" / "symlink" to link them
+	Link string
+	// Absolute makes symlink targets absolute instead of relative
+	Absolute bool
+}
+
+const scriptHeader = `#!/bin/sh
+# Generated by dupe-checker on %s
+#
+# Review every block before running. Each block first checks that the size
+# and modification time of its files still match the scan, and the script
+# stops at the first file that changed.
+set -eu
+
+check() {
+	actual=$(stat -c '%%s %%Y' -- "$1" 2>/dev/null || stat -f '%%z %%m' -- "$1" 2>/dev/null) || actual=missing
+	if [ "$actual" != "$2 $3" ]; then
+		printf 'dupe-checker: %%s changed since the scan, aborting\n' "$1" >&2
+		exit 1
+	fi
+}
+`
+
+// WriteScript writes a POSIX shell script carrying out plan, one commented
+// block per duplicate group, for review before anything is touched
+func WriteScript(w io.Writer, plan Plan, opts ScriptOptions, generated time.Time) error {
+	switch opts.Link {
+	case "", "hard", "symlink":
+	default:
+		return fmt.Errorf("%q links cannot be expressed in a script", opts.Link)
+	}
+
+	if _, err := fmt.Fprintf(w, scriptHeader, generated.Format(time.RFC3339)); err != nil {
+		return err
+	}
+
+	for i, gp := range plan.Groups {
+		var b strings.Builder
+		fmt.Fprintf(&b, "\n# Group %d: %d files, %d bytes each, hash %016x\n", i+1, len(gp.Remove)+1, gp.Group.Size, gp.Group.Hash)
+		fmt.Fprintf(&b, "# keep %s\n", comment(gp.Keep))
+		if gp.Reason != "" {
+			fmt.Fprintf(&b, "# chosen by %s\n", strings.ReplaceAll(gp.Reason, "\n", " "))
+		}
+
+		// Absolute paths keep the script independent of where it is run from
+		keep, err := filepath.Abs(gp.Keep)
+		if err != nil {
+			return err
+		}
+		for _, path := range append([]string{gp.Keep}, gp.Remove...) {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "check %s %d %d\n", shellQuote(abs), gp.Group.Size, ScanModTime(gp.Group, path))
+		}
+
+		for _, path := range gp.Remove {
+			dup, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			switch opts.Link {
+			case "":
+				fmt.Fprintf(&b, "rm -f -- %s\n", shellQuote(dup))
+			case "hard":
+				// ln would fail and stop the script halfway through
+				if !linkable(keep, dup) {
+					fmt.Fprintf(&b, "# skipped: different filesystem: %s\n", comment(dup))
+					continue
+				}
+				fmt.Fprintf(&b, "ln -f -- %s %s\n", shellQuote(keep), shellQuote(dup))
+			case "symlink":
+				target := keep
+				if !opts.Absolute {
+					if target, err = filepath.Rel(filepath.Dir(dup), keep); err != nil {
+						return err
+					}
+				}
+				fmt.Fprintf(&b, "ln -sf -- %s %s\n", shellQuote(target), shellQuote(dup))
+			}
+		}
+
+		if _, err := io.WriteString(w, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkable reports whether dup can be hard linked to keep. Files that cannot
+// be examined are assumed linkable; the script's checks stop it if they changed.
+func linkable(keep, dup string) bool {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return true
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return true
+	}
+	return sameDevice(keep, dup, keepInfo, dupInfo)
+}
+
+// shellQuote single-quotes s for a POSIX shell, closing and reopening the
+// quotes around any embedded single quote
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// comment makes a path safe to show after # by quoting it and keeping it on one line
+func comment(path string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`).Replace(shellQuote(path))
+}
+
+// CreateScript writes the script to path with execute permission
+func CreateScript(path string, plan Plan, opts ScriptOptions) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if err := WriteScript(f, plan, opts, time.Now()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
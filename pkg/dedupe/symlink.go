@@ -0,0 +1,231 @@
+package dedupe
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LinkRecord describes one duplicate replaced by a symlink, with enough of
+// the original metadata to turn it back into a regular file
+type LinkRecord struct {
+	Link    string      `json:"link"`
+	Target  string      `json:"target"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+}
+
+// Symlink replaces duplicates with symbolic links to the kept file. Links
+// are relative unless Absolute is set, and are only created when both ends
+// resolve inside one of Roots. Every replacement is written to Record.
+type Symlink struct {
+	Absolute bool
+	Roots    []string
+	Record   *Recorder
+}
+
+func (Symlink) Name() string {
+	return "symlink"
+}
+
+func (s Symlink) Apply(keep, dup string, size int64) (int64, error) {
+	freed, err := s.Estimate(keep, dup, size)
+	if err != nil {
+		return 0, err
+	}
+
+	target, err := s.target(keep, dup)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Lstat(dup)
+	if err != nil {
+		return 0, err
+	}
+	absKeep, err := filepath.Abs(keep)
+	if err != nil {
+		return 0, err
+	}
+	absDup, err := filepath.Abs(dup)
+	if err != nil {
+		return 0, err
+	}
+
+	// Record first: a link without a record could not be reverted
+	if s.Record != nil {
+		rec := LinkRecord{Link: absDup, Target: absKeep, Size: size, Mode: info.Mode().Perm(), ModTime: info.ModTime()}
+		if err := s.Record.Add(rec); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := replaceWith(dup, func(tmp string) error {
+		return os.Symlink(target, tmp)
+	}); err != nil {
+		return 0, err
+	}
+	return freed, nil
+}
+
+// Estimate refuses links that would leave the scan roots and returns the
+// bytes replacing dup would free
+func (s Symlink) Estimate(keep, dup string, size int64) (int64, error) {
+	keepInfo, err := os.Stat(keep)
+	if err != nil {
+		return 0, err
+	}
+	dupInfo, err := os.Lstat(dup)
+	if err != nil {
+		return 0, err
+	}
+	if !dupInfo.Mode().IsRegular() {
+		return 0, Skip("not a regular file")
+	}
+	if os.SameFile(keepInfo, dupInfo) {
+		return 0, Skip("hard linked to kept file")
+	}
+
+	if _, err := s.target(keep, dup); err != nil {
+		return 0, err
+	}
+
+	if linkCount(dupInfo) > 1 {
+		return 0, nil
+	}
+	return size, nil
+}
+
+// target returns the link text for dup. The kept file and dup's directory
+// are resolved through any symlinks so the check reflects where the link
+// really points.
+func (s Symlink) target(keep, dup string) (string, error) {
+	realKeep, err := filepath.EvalSymlinks(keep)
+	if err != nil {
+		return "", err
+	}
+	realKeep, err = filepath.Abs(realKeep)
+	if err != nil {
+		return "", err
+	}
+	realDir, err := filepath.EvalSymlinks(filepath.Dir(dup))
+	if err != nil {
+		return "", err
+	}
+	realDir, err = filepath.Abs(realDir)
+	if err != nil {
+		return "", err
+	}
+
+	if !s.insideRoots(realKeep) || !s.insideRoots(filepath.Join(realDir, filepath.Base(dup))) {
+		return "", Skip("link would point outside the scan roots")
+	}
+
+	if s.Absolute {
+		return realKeep, nil
+	}
+	rel, err := filepath.Rel(realDir, realKeep)
+	if err != nil {
+		return "", Skip("no relative path to kept file")
+	}
+	return rel, nil
+}
+
+func (s Symlink) insideRoots(path string) bool {
+	for _, root := range s.Roots {
+		realRoot, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if isWithin(path, realRoot) {
+			return true
+		}
+	}
+	return false
+}
+
+// RevertLinks turns every symlink listed in a record file back into a
+// regular copy of its target with the original mode and mtime. Links that
+// were changed or removed since are skipped. audit, when set, logs every
+// reverted link.
+func RevertLinks(recordPath string, audit *AuditLog) (Summary, error) {
+	records, err := readRecords[LinkRecord](recordPath)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	summary := Summary{Action: "restore"}
+	for _, rec := range records {
+		result := Result{Keep: rec.Target, Path: rec.Link}
+		entry := AuditEntry{Action: "revert-link", Source: rec.Link, Target: rec.Target, Size: rec.Size}
+		if audit != nil {
+			entry.Digest, _ = fileDigest(rec.Target)
+		}
+		_, result.Err = audit.perform(entry, func() (int64, error) {
+			return 0, revertLink(rec)
+		})
+		tally(&summary, &result, rec.Size)
+		summary.Results = append(summary.Results, result)
+	}
+
+	// Reverting frees nothing
+	summary.BytesReclaimed = 0
+	summary.Groups = countKeeps(summary.Results)
+	return summary, nil
+}
+
+func revertLink(rec LinkRecord) error {
+	info, err := os.Lstat(rec.Link)
+	if err != nil {
+		return Skip("link no longer exists")
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return Skip("no longer a symlink")
+	}
+	resolved, err := filepath.EvalSymlinks(rec.Link)
+	if err != nil {
+		return err
+	}
+	realTarget, err := filepath.EvalSymlinks(rec.Target)
+	if err != nil {
+		return err
+	}
+	if resolved != realTarget {
+		return Skip("symlink now points elsewhere")
+	}
+
+	return replaceWith(rec.Link, func(tmp string) error {
+		if err := copyFile(rec.Target, tmp, rec.Mode); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		return os.Chtimes(tmp, rec.ModTime, rec.ModTime)
+	})
+}
+
+// copyFile copies src to a new file dst created with mode
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
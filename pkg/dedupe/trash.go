@@ -0,0 +1,248 @@
+package dedupe
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Trash moves duplicates into the freedesktop.org trash so they can be
+// restored from a file manager. Files on the home filesystem go to the home
+// trash; files on other mounts go to that mount's .Trash/$uid or .Trash-$uid.
+type Trash struct {
+	// Home is the home trash directory, usually ~/.local/share/Trash
+	Home string
+}
+
+// NewTrash locates the home trash following $XDG_DATA_HOME
+func NewTrash() (Trash, error) {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Trash{}, err
+		}
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	return Trash{Home: filepath.Join(dataHome, "Trash")}, nil
+}
+
+func (Trash) Name() string {
+	return "trash"
+}
+
+func (Trash) MovesFiles() {}
+
+func (t Trash) Apply(keep, dup string, size int64) (int64, error) {
+	if _, err := t.Estimate(keep, dup, size); err != nil {
+		return 0, err
+	}
+	abs, err := filepath.Abs(dup)
+	if err != nil {
+		return 0, err
+	}
+
+	trashDir, topDir, err := t.dirFor(abs)
+	if err != nil {
+		return 0, err
+	}
+
+	// Paths in a mount's own trash are stored relative to the mount point
+	infoPath := abs
+	if topDir != "" {
+		if rel, err := filepath.Rel(topDir, abs); err == nil {
+			infoPath = rel
+		}
+	}
+
+	name, err := writeTrashInfo(trashDir, filepath.Base(abs), infoPath, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if err := os.Rename(abs, filepath.Join(trashDir, "files", name)); err != nil {
+		os.Remove(filepath.Join(trashDir, "info", name+".trashinfo"))
+		return 0, err
+	}
+	// Nothing is freed until the trash is emptied
+	return 0, nil
+}
+
+// Estimate checks that dup can be trashed. Trashing frees nothing.
+func (t Trash) Estimate(keep, dup string, size int64) (int64, error) {
+	for _, path := range []string{keep, dup} {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return 0, err
+		}
+		if t.contains(abs) {
+			// A trashed copy may be deleted for good at any time
+			return 0, Skip(path + " is in the trash")
+		}
+	}
+	if _, err := distinctFile(keep, dup); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// Dirs lists the trash directories files under roots could end up in, so
+// scans can leave them out
+func (t Trash) Dirs(roots []string) []string {
+	var dirs []string
+	if t.Home != "" {
+		dirs = append(dirs, t.Home)
+	}
+	uid := strconv.Itoa(os.Getuid())
+	for _, root := range roots {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(abs)
+		if err != nil {
+			continue
+		}
+		dev, ok := deviceID(info)
+		if !ok {
+			continue
+		}
+		// mountPoint starts at the parent of the path it is given
+		topDir, err := mountPoint(filepath.Join(abs, "child"), dev)
+		if err != nil {
+			continue
+		}
+		dirs = append(dirs, filepath.Join(topDir, ".Trash", uid), filepath.Join(topDir, ".Trash-"+uid))
+	}
+	return dirs
+}
+
+// contains reports whether abs lies in the home trash or a per-mount trash
+func (t Trash) contains(abs string) bool {
+	if t.Home != "" && isWithin(abs, t.Home) {
+		return true
+	}
+	uid := strconv.Itoa(os.Getuid())
+	for dir := filepath.Dir(abs); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		base := filepath.Base(dir)
+		if base == ".Trash-"+uid || (base == uid && filepath.Base(filepath.Dir(dir)) == ".Trash") {
+			return true
+		}
+	}
+	return false
+}
+
+// holds reports whether path lies in a trash directory
+func (t Trash) holds(path string) bool {
+	abs, err := filepath.Abs(path)
+	return err == nil && t.contains(abs)
+}
+
+// dirFor picks the trash directory for a file, creating it if needed. topDir
+// is the mount point when a per-mount trash is used, and empty otherwise.
+func (t Trash) dirFor(abs string) (trashDir, topDir string, err error) {
+	info, err := os.Lstat(abs)
+	if err != nil {
+		return "", "", err
+	}
+	dev, ok := deviceID(info)
+	if !ok {
+		return "", "", Skip("the freedesktop trash is not supported on this platform")
+	}
+
+	if err := os.MkdirAll(t.Home, 0700); err == nil {
+		if homeInfo, err := os.Stat(t.Home); err == nil {
+			if homeDev, ok := deviceID(homeInfo); ok && homeDev == dev {
+				return t.Home, "", prepareTrash(t.Home)
+			}
+		}
+	}
+
+	topDir, err = mountPoint(abs, dev)
+	if err != nil {
+		return "", "", err
+	}
+	uid := strconv.Itoa(os.Getuid())
+
+	// An admin-created .Trash must be a real sticky directory to be trusted
+	shared := filepath.Join(topDir, ".Trash")
+	if st, err := os.Lstat(shared); err == nil && st.IsDir() && st.Mode()&os.ModeSticky != 0 {
+		dir := filepath.Join(shared, uid)
+		if err := prepareTrash(dir); err == nil {
+			return dir, topDir, nil
+		}
+	}
+
+	dir := filepath.Join(topDir, ".Trash-"+uid)
+	if st, err := os.Lstat(dir); err == nil && (!st.IsDir() || st.Mode()&os.ModeSymlink != 0) {
+		return "", "", Skip(dir + " is not a directory, no usable trash on this filesystem")
+	}
+	if err := prepareTrash(dir); err != nil {
+		return "", "", Skip(fmt.Sprintf("no usable trash on this filesystem: %v", err))
+	}
+	return dir, topDir, nil
+}
+
+// prepareTrash makes sure a trash directory has its files and info subdirectories
+func prepareTrash(dir string) error {
+	for _, sub := range []string{"files", "info"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountPoint walks up from abs until the parent is on another device
+func mountPoint(abs string, dev uint64) (string, error) {
+	dir := filepath.Dir(abs)
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		info, err := os.Lstat(parent)
+		if err != nil {
+			return "", err
+		}
+		if parentDev, ok := deviceID(info); !ok || parentDev != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
+
+// writeTrashInfo claims a free name in the trash by creating its .trashinfo
+// file exclusively, and returns that name
+func writeTrashInfo(trashDir, base, path string, deleted time.Time) (string, error) {
+	content := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: filepath.ToSlash(path)}).EscapedPath(), deleted.Format("2006-01-02T15:04:05"))
+
+	name := base
+	for i := 2; ; i, name = i+1, base+"."+strconv.Itoa(i) {
+		// A stray file without info still occupies the name
+		if _, err := os.Lstat(filepath.Join(trashDir, "files", name)); err == nil {
+			continue
+		}
+		f, err := os.OpenFile(filepath.Join(trashDir, "info", name+".trashinfo"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if _, err := f.WriteString(content); err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "", err
+		}
+		if err := f.Close(); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		return name, nil
+	}
+}
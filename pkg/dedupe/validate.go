@@ -0,0 +1,121 @@
+package dedupe
+
+import (
+	"bytes"
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"io"
+	"os"
+)
+
+// validateGroup re-checks a group right before it is acted on. Every file
+// must still have the size, mtime and inode recorded by the scan, and every
+// duplicate must be a different file that still matches the kept file byte
+// for byte. The returned
+// SkipError says what changed.
+func validateGroup(gp GroupPlan) error {
+	for _, path := range append([]string{gp.Keep}, gp.Remove...) {
+		if err := validateFile(gp.Group, path); err != nil {
+			return err
+		}
+	}
+
+	keepInfo, err := os.Stat(gp.Keep)
+	if err != nil {
+		return Skip(fmt.Sprintf("group changed since the scan: %s is gone", gp.Keep))
+	}
+	for _, dup := range gp.Remove {
+		// A single-link file seen twice is one directory entry reached
+		// through two paths, e.g. a symlinked directory or a bind mount;
+		// acting on the "duplicate" would destroy the kept file
+		if dupInfo, err := os.Lstat(dup); err == nil && os.SameFile(keepInfo, dupInfo) && linkCount(dupInfo) == 1 {
+			return Skip(fmt.Sprintf("%s is the kept file %s under another path", dup, gp.Keep))
+		}
+
+		same, err := sameContent(gp.Keep, dup)
+		if err != nil {
+			return Skip(fmt.Sprintf("group changed since the scan: cannot compare %s: %v", dup, err))
+		}
+		if !same {
+			return Skip(fmt.Sprintf("group changed since the scan: %s no longer matches %s", dup, gp.Keep))
+		}
+	}
+	return nil
+}
+
+// validateFile compares a file's current metadata with the scan
+func validateFile(group scanner.DuplicateGroup, path string) error {
+	changed := func(what string) error {
+		return Skip(fmt.Sprintf("group changed since the scan: %s %s", path, what))
+	}
+
+	info, err := os.Lstat(path)
+	if err != nil {
+		return changed("is gone")
+	}
+	if !info.Mode().IsRegular() {
+		return changed("is no longer a regular file")
+	}
+	if info.Size() != group.Size {
+		return changed("changed size")
+	}
+
+	scanned, ok := scanInfo(group, path)
+	if !ok {
+		return nil
+	}
+	if info.ModTime().Unix() != scanned.ModTime {
+		return changed("was modified")
+	}
+	if dev, ino, ok := scanner.FileID(info); ok && scanned.Inode != 0 && (dev != scanned.Dev || ino != scanned.Inode) {
+		return changed("was replaced by another file")
+	}
+	return nil
+}
+
+// scanInfo returns what the scan recorded about path
+func scanInfo(group scanner.DuplicateGroup, path string) (scanner.FileInfo, bool) {
+	for _, info := range group.Infos {
+		if info.Path == path {
+			return info, true
+		}
+	}
+	return scanner.FileInfo{}, false
+}
+
+// sameContent compares two files byte for byte
+func sameContent(a, b string) (bool, error) {
+	fa, err := os.Open(a)
+	if err != nil {
+		return false, err
+	}
+	defer fa.Close()
+
+	fb, err := os.Open(b)
+	if err != nil {
+		return false, err
+	}
+	defer fb.Close()
+
+	bufA := make([]byte, 64*1024)
+	bufB := make([]byte, 64*1024)
+	for {
+		na, errA := io.ReadFull(fa, bufA)
+		nb, errB := io.ReadFull(fb, bufB)
+		if !bytes.Equal(bufA[:na], bufB[:nb]) {
+			return false, nil
+		}
+
+		endA := errA == io.EOF || errA == io.ErrUnexpectedEOF
+		endB := errB == io.EOF || errB == io.ErrUnexpectedEOF
+		if errA != nil && !endA {
+			return false, errA
+		}
+		if errB != nil && !endB {
+			return false, errB
+		}
+		if endA || endB {
+			return endA == endB, nil
+		}
+	}
+}
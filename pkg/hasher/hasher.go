@@ -1,17 +1,18 @@
 package hasher
 
 import (
+	"dupe-file-checker/pkg/archive"
 	"fmt"
 	"io"
-	"os"
 	"sync"
 
 	"github.com/cespare/xxhash/v2"
 )
 
+// QuickHash identifies files by size and head content only; copies rarely
+// share an mtime (archive members never do), so it is not part of the key
 type QuickHash struct {
 	Size     int64
-	ModTime  int64
 	HeadHash uint64
 }
 
@@ -23,8 +24,8 @@ var bufferPool = sync.Pool{
 	},
 }
 
-func ComputeQuickHash(path string, size int64, modTime int64) (QuickHash, error) {
-	f, err := os.Open(path)
+func ComputeQuickHash(path string, size int64) (QuickHash, error) {
+	f, err := archive.Open(path)
 	if err != nil {
 		return QuickHash{}, err
 	}
@@ -33,8 +34,9 @@ func ComputeQuickHash(path string, size int64, modTime int64) (QuickHash, error)
 	buf := bufferPool.Get().([]byte)
 	defer bufferPool.Put(buf)
 
-	n, err := f.Read(buf)
-	if err != nil && err != io.EOF {
+	// Archive members are decompressed streams, so a single Read may come up short
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
 		return QuickHash{}, err
 	}
 
@@ -43,13 +45,12 @@ func ComputeQuickHash(path string, size int64, modTime int64) (QuickHash, error)
 
 	return QuickHash{
 		Size:     size,
-		ModTime:  modTime,
 		HeadHash: h.Sum64(),
 	}, nil
 }
 
 func ComputeFullHash(path string) (uint64, error) {
-	f, err := os.Open(path)
+	f, err := archive.Open(path)
 	if err != nil {
 		return 0, err
 	}
@@ -65,5 +66,98 @@ func ComputeFullHash(path string) (uint64, error) {
 }
 
 func (q QuickHash) String() string {
-	return fmt.Sprintf("%d-%d-%x", q.Size, q.ModTime, q.HeadHash)
+	return fmt.Sprintf("%d-%x", q.Size, q.HeadHash)
+}
+
+// ComputePrefixHash hashes the first n bytes of a file, failing if it is shorter
+func ComputePrefixHash(path string, n int64) (uint64, error) {
+	f, err := archive.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	h := xxhash.New()
+	copied, err := io.Copy(h, io.LimitReader(f, n))
+	if err != nil {
+		return 0, err
+	}
+	if copied < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return h.Sum64(), nil
+}
+
+// ComputeRangeHash hashes n bytes of a file starting at offset, failing if
+// the file ends first. Plain files are seeked, so sampling deep into a large
+// file stays cheap.
+func ComputeRangeHash(path string, offset, n int64) (uint64, error) {
+	f, err := archive.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if seeker, ok := f.(io.Seeker); ok {
+		_, err = seeker.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, f, offset)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	h := xxhash.New()
+	copied, err := io.Copy(h, io.LimitReader(f, n))
+	if err != nil {
+		return 0, err
+	}
+	if copied < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return h.Sum64(), nil
+}
+
+// MemberHashes holds the quick and full hash of an archive member
+type MemberHashes struct {
+	Quick QuickHash
+	Full  uint64
+}
+
+// ComputeMemberHashes computes both hashes for the given members of one
+// archive in a single pass over it. Members that cannot be read are left out.
+func ComputeMemberHashes(archivePath string, members []string) (map[string]MemberHashes, error) {
+	wanted := make(map[string]bool, len(members))
+	for _, m := range members {
+		wanted[m] = true
+	}
+
+	hashes := make(map[string]MemberHashes, len(members))
+	err := archive.ReadMembers(archivePath, wanted, func(name string, r io.Reader) error {
+		buf := bufferPool.Get().([]byte)
+		defer bufferPool.Put(buf)
+
+		n, err := io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		head := xxhash.New()
+		head.Write(buf[:n])
+		full := xxhash.New()
+		full.Write(buf[:n])
+		rest, err := io.Copy(full, r)
+		if err != nil {
+			return err
+		}
+
+		hashes[name] = MemberHashes{
+			Quick: QuickHash{Size: int64(n) + rest, HeadHash: head.Sum64()},
+			Full:  full.Sum64(),
+		}
+		return nil
+	})
+	return hashes, err
 }
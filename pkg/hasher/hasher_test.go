@@ -1,6 +1,9 @@
 package hasher
 
 import (
+	"bytes"
+	"image"
+	"image/jpeg"
 	"os"
 	"path/filepath"
 	"testing"
@@ -16,7 +19,7 @@ func TestComputeQuickHash(t *testing.T) {
 	}
 
 	info, _ := os.Stat(testFile)
-	qh, err := ComputeQuickHash(testFile, info.Size(), info.ModTime().Unix())
+	qh, err := ComputeQuickHash(testFile, info.Size())
 	if err != nil {
 		t.Fatalf("ComputeQuickHash failed: %v", err)
 	}
@@ -42,8 +45,8 @@ func TestComputeQuickHashIdentical(t *testing.T) {
 	info1, _ := os.Stat(file1)
 	info2, _ := os.Stat(file2)
 
-	qh1, _ := ComputeQuickHash(file1, info1.Size(), info1.ModTime().Unix())
-	qh2, _ := ComputeQuickHash(file2, info2.Size(), info2.ModTime().Unix())
+	qh1, _ := ComputeQuickHash(file1, info1.Size())
+	qh2, _ := ComputeQuickHash(file2, info2.Size())
 
 	if qh1.HeadHash != qh2.HeadHash {
 		t.Error("Expected identical files to have same head hash")
@@ -101,3 +104,91 @@ func TestComputeFullHashDifferent(t *testing.T) {
 		t.Error("Expected different files to have different hashes")
 	}
 }
+
+func TestComputeRangeHash(t *testing.T) {
+	tmpDir := t.TempDir()
+	file1 := filepath.Join(tmpDir, "file1.bin")
+	file2 := filepath.Join(tmpDir, "file2.bin")
+
+	os.WriteFile(file1, []byte("header-shared-middle-one"), 0644)
+	os.WriteFile(file2, []byte("HEADER-shared-middle-two"), 0644)
+
+	a, err := ComputeRangeHash(file1, 7, 6)
+	if err != nil {
+		t.Fatalf("ComputeRangeHash failed: %v", err)
+	}
+	b, _ := ComputeRangeHash(file2, 7, 6)
+	if a != b {
+		t.Error("Expected equal ranges to hash the same")
+	}
+
+	if _, err := ComputeRangeHash(file1, 20, 10); err == nil {
+		t.Error("Expected an error for a range past the end of the file")
+	}
+}
+
+// jpegWithSegment encodes a small JPEG and inserts an extra metadata segment after SOI
+func jpegWithSegment(t *testing.T, marker byte, payload string) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	data := buf.Bytes()
+	if payload == "" {
+		return data
+	}
+	length := len(payload) + 2
+	segment := append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+	return append(append(append([]byte{}, data[:2]...), segment...), data[2:]...)
+}
+
+func TestComputeJPEGHashIgnoresMetadata(t *testing.T) {
+	tmpDir := t.TempDir()
+	plain := filepath.Join(tmpDir, "plain.jpg")
+	tagged := filepath.Join(tmpDir, "tagged.jpg")
+	commented := filepath.Join(tmpDir, "commented.jpg")
+
+	os.WriteFile(plain, jpegWithSegment(t, 0, ""), 0644)
+	os.WriteFile(tagged, jpegWithSegment(t, 0xE1, "Exif\x00\x00rating=5"), 0644)
+	os.WriteFile(commented, jpegWithSegment(t, 0xFE, "edited"), 0644)
+
+	h1, err := ComputeJPEGHash(plain)
+	if err != nil {
+		t.Fatalf("ComputeJPEGHash failed: %v", err)
+	}
+	h2, _ := ComputeJPEGHash(tagged)
+	h3, _ := ComputeJPEGHash(commented)
+
+	if h1.Image != h2.Image || h1.Image != h3.Image {
+		t.Error("Expected identical image data hashes despite metadata changes")
+	}
+	if _, ok := h2.Metadata["EXIF"]; !ok {
+		t.Errorf("Expected EXIF segment to be recorded, got %v", h2.Metadata)
+	}
+	if _, ok := h3.Metadata["Comment"]; !ok {
+		t.Errorf("Expected comment segment to be recorded, got %v", h3.Metadata)
+	}
+
+	full1, _ := ComputeFullHash(plain)
+	full2, _ := ComputeFullHash(tagged)
+	if full1 == full2 {
+		t.Error("Expected full hashes to differ")
+	}
+}
+
+func TestComputeJPEGHashRejectsOtherFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "fake.jpg")
+	os.WriteFile(file, []byte("not a jpeg"), 0644)
+
+	if _, err := ComputeJPEGHash(file); err == nil {
+		t.Error("Expected error for non-JPEG content")
+	}
+}
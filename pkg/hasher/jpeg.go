@@ -0,0 +1,183 @@
+package hasher
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// JPEGHash separates the hash of a JPEG's image data from its metadata
+type JPEGHash struct {
+	// Image covers quantisation and Huffman tables, frame and scan headers
+	// and the entropy-coded scan data
+	Image uint64
+
+	// Metadata maps each APPn/COM segment label to the hash of its payload
+	Metadata map[string]uint64
+}
+
+var errNotJPEG = errors.New("not a JPEG file")
+
+const (
+	markerSOI = 0xD8
+	markerEOI = 0xD9
+	markerSOS = 0xDA
+	markerCOM = 0xFE
+)
+
+// ComputeJPEGHash hashes the image data of a JPEG while skipping APPn and COM
+// segments, so re-tagged copies of a photo hash the same
+func ComputeJPEGHash(path string) (JPEGHash, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return JPEGHash{}, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != markerSOI {
+		return JPEGHash{}, errNotJPEG
+	}
+
+	result := JPEGHash{Metadata: make(map[string]uint64)}
+	h := xxhash.New()
+
+	marker, err := nextMarker(r)
+	for err == nil && marker != markerEOI {
+		// Standalone markers carry no length
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			h.Write([]byte{0xFF, marker})
+			marker, err = nextMarker(r)
+			continue
+		}
+
+		var lenBuf [2]byte
+		if _, err = io.ReadFull(r, lenBuf[:]); err != nil {
+			break
+		}
+		length := int(lenBuf[0])<<8 | int(lenBuf[1])
+		if length < 2 {
+			return JPEGHash{}, fmt.Errorf("%s: bad segment length", path)
+		}
+		payload := make([]byte, length-2)
+		if _, err = io.ReadFull(r, payload); err != nil {
+			break
+		}
+
+		if isMetadataMarker(marker) {
+			label := metadataLabel(marker, payload)
+			// Repeated segments (e.g. multi-part ICC profiles) are folded together
+			mh := xxhash.New()
+			if prev, ok := result.Metadata[label]; ok {
+				fmt.Fprintf(mh, "%x", prev)
+			}
+			mh.Write(payload)
+			result.Metadata[label] = mh.Sum64()
+			marker, err = nextMarker(r)
+			continue
+		}
+
+		h.Write([]byte{0xFF, marker})
+		h.Write(lenBuf[:])
+		h.Write(payload)
+
+		if marker == markerSOS {
+			marker, err = copyScanData(r, h)
+			continue
+		}
+		marker, err = nextMarker(r)
+	}
+
+	if err != nil {
+		return JPEGHash{}, fmt.Errorf("%s: truncated JPEG: %w", path, err)
+	}
+
+	result.Image = h.Sum64()
+	return result, nil
+}
+
+func isMetadataMarker(marker byte) bool {
+	return (marker >= 0xE0 && marker <= 0xEF) || marker == markerCOM
+}
+
+// metadataLabel names a metadata segment by its well-known identifier
+func metadataLabel(marker byte, payload []byte) string {
+	if marker == markerCOM {
+		return "Comment"
+	}
+
+	prefixes := []struct {
+		prefix string
+		label  string
+	}{
+		{"JFIF\x00", "JFIF"},
+		{"JFXX\x00", "JFIF thumbnail"},
+		{"Exif\x00", "EXIF"},
+		{"http://ns.adobe.com/xap/1.0/", "XMP"},
+		{"http://ns.adobe.com/xmp/extension/", "XMP"},
+		{"ICC_PROFILE\x00", "ICC profile"},
+		{"Photoshop 3.0\x00", "IPTC"},
+		{"Adobe", "Adobe"},
+	}
+	for _, p := range prefixes {
+		if len(payload) >= len(p.prefix) && string(payload[:len(p.prefix)]) == p.prefix {
+			return p.label
+		}
+	}
+	return fmt.Sprintf("APP%d", marker-0xE0)
+}
+
+// nextMarker skips to the next marker and returns its code
+func nextMarker(r *bufio.Reader) (byte, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, errNotJPEG
+	}
+	for b == 0xFF {
+		// Any number of fill bytes may precede a marker
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// copyScanData feeds entropy-coded data into h up to the next real marker,
+// which it returns. Stuffed zero bytes and restart markers belong to the scan.
+func copyScanData(r *bufio.Reader, h io.Writer) (byte, error) {
+	w := bufio.NewWriter(h)
+	defer w.Flush()
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != 0xFF {
+			w.WriteByte(b)
+			continue
+		}
+
+		next, err := r.ReadByte()
+		for err == nil && next == 0xFF {
+			next, err = r.ReadByte()
+		}
+		if err != nil {
+			return 0, err
+		}
+		if next == 0x00 || (next >= 0xD0 && next <= 0xD7) {
+			w.WriteByte(0xFF)
+			w.WriteByte(next)
+			continue
+		}
+		return next, nil
+	}
+}
@@ -0,0 +1,158 @@
+package imagehash
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// The standard library has no BMP decoder, so a minimal one covering the
+// uncompressed 8, 24 and 32 bit layouts is registered here
+
+var errUnsupportedBMP = errors.New("unsupported BMP variant")
+
+// maxBMPPixels bounds the image allocated from a header, which is read
+// before any pixel data proves the file is really that large
+const maxBMPPixels = 1 << 26
+
+func init() {
+	image.RegisterFormat("bmp", "BM", decodeBMP, decodeBMPConfig)
+}
+
+type bmpHeader struct {
+	width, height int
+	topDown       bool
+	bitCount      int
+	pixelOffset   int
+	paletteSize   int
+	headerSize    int
+}
+
+func readBMPHeader(r io.Reader) (bmpHeader, error) {
+	// 14 byte file header followed by at least the 40 byte info header
+	buf := make([]byte, 54)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return bmpHeader{}, err
+	}
+	if buf[0] != 'B' || buf[1] != 'M' {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+
+	h := bmpHeader{
+		pixelOffset: int(binary.LittleEndian.Uint32(buf[10:14])),
+		headerSize:  int(binary.LittleEndian.Uint32(buf[14:18])),
+		width:       int(int32(binary.LittleEndian.Uint32(buf[18:22]))),
+		height:      int(int32(binary.LittleEndian.Uint32(buf[22:26]))),
+		bitCount:    int(binary.LittleEndian.Uint16(buf[28:30])),
+		paletteSize: int(binary.LittleEndian.Uint32(buf[46:50])),
+	}
+	compression := binary.LittleEndian.Uint32(buf[30:34])
+
+	if h.headerSize < 40 || h.width <= 0 || h.height == 0 {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+	if h.height < 0 {
+		h.height = -h.height
+		h.topDown = true
+	}
+	if h.width > maxBMPPixels/h.height {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+	// BI_RGB, or BI_BITFIELDS with the usual BGRA masks for 32 bit images
+	if compression != 0 && !(compression == 3 && h.bitCount == 32) {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+	if h.bitCount != 8 && h.bitCount != 24 && h.bitCount != 32 {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+	if h.bitCount == 8 && h.paletteSize == 0 {
+		h.paletteSize = 256
+	}
+	if h.bitCount == 8 && h.paletteSize > 256 {
+		return bmpHeader{}, errUnsupportedBMP
+	}
+	return h, nil
+}
+
+func decodeBMPConfig(r io.Reader) (image.Config, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{ColorModel: color.NRGBAModel, Width: h.width, Height: h.height}, nil
+}
+
+func decodeBMP(r io.Reader) (image.Image, error) {
+	h, err := readBMPHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the rest of the info header, reading the palette if there is
+	// one. Only the palette is buffered; the header's offset is not trusted
+	// for an allocation.
+	if h.pixelOffset < 54 {
+		return nil, errUnsupportedBMP
+	}
+	gap := int64(h.pixelOffset - 54)
+
+	var palette []color.NRGBA
+	if h.bitCount == 8 {
+		start := int64(h.headerSize - 40)
+		if start+int64(h.paletteSize)*4 > gap {
+			return nil, errUnsupportedBMP
+		}
+		if _, err := io.CopyN(io.Discard, r, start); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, h.paletteSize*4)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		for i := 0; i < h.paletteSize; i++ {
+			p := buf[i*4:]
+			palette = append(palette, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF})
+		}
+		gap -= start + int64(len(buf))
+	}
+	if _, err := io.CopyN(io.Discard, r, gap); err != nil {
+		return nil, err
+	}
+
+	bytesPerPixel := h.bitCount / 8
+	stride := (h.width*bytesPerPixel + 3) &^ 3
+	row := make([]byte, stride)
+	img := image.NewNRGBA(image.Rect(0, 0, h.width, h.height))
+
+	for i := 0; i < h.height; i++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		y := h.height - 1 - i
+		if h.topDown {
+			y = i
+		}
+
+		for x := 0; x < h.width; x++ {
+			var c color.NRGBA
+			switch h.bitCount {
+			case 8:
+				idx := int(row[x])
+				if idx >= len(palette) {
+					return nil, errUnsupportedBMP
+				}
+				c = palette[idx]
+			case 24:
+				p := row[x*3:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+			case 32:
+				p := row[x*4:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
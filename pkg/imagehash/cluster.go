@@ -0,0 +1,176 @@
+package imagehash
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// Member is one image of a cluster, scored against the cluster's representative
+type Member struct {
+	Path   string
+	Size   int64
+	Hashes Hashes
+
+	// Hamming distances to the representative for each hash
+	AverageDistance    int
+	DifferenceDistance int
+	PerceptualDistance int
+}
+
+// Similarity is the perceptual-hash similarity to the representative
+func (m Member) Similarity() float64 {
+	return Similarity(m.PerceptualDistance)
+}
+
+// Cluster groups visually similar images; Members[0] is the representative
+type Cluster struct {
+	Members []Member
+}
+
+// bkNode is a node of a BK-tree keyed on Hamming distance, which lets
+// neighbour queries skip most of the index instead of comparing all pairs
+type bkNode struct {
+	hash     uint64
+	index    int
+	children map[int]*bkNode
+}
+
+func (n *bkNode) insert(hash uint64, index int) {
+	for {
+		d := Distance(n.hash, hash)
+		child := n.children[d]
+		if child == nil {
+			if n.children == nil {
+				n.children = make(map[int]*bkNode)
+			}
+			n.children[d] = &bkNode{hash: hash, index: index}
+			return
+		}
+		n = child
+	}
+}
+
+// within appends the index of every node no further than maxDistance from hash
+func (n *bkNode) within(hash uint64, maxDistance int, found []int) []int {
+	d := Distance(n.hash, hash)
+	if d <= maxDistance {
+		found = append(found, n.index)
+	}
+	for cd, child := range n.children {
+		if cd >= d-maxDistance && cd <= d+maxDistance {
+			found = child.within(hash, maxDistance, found)
+		}
+	}
+	return found
+}
+
+// FindClusters hashes every decodable image and groups those whose
+// perceptual hashes are within maxDistance bits of a representative. The
+// largest images are picked as representatives first, and every member is
+// within maxDistance of its own representative, so clusters never chain
+// through intermediate images.
+func FindClusters(files []scanner.FileInfo, maxDistance int) []Cluster {
+	return cluster(computeAll(files), maxDistance)
+}
+
+// cluster groups hashed images around representatives
+func cluster(members []Member, maxDistance int) []Cluster {
+	if len(members) < 2 {
+		return nil
+	}
+
+	// The largest file is most likely the original so it represents the cluster
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Size > members[j].Size
+	})
+
+	root := &bkNode{hash: members[0].Hashes.Perceptual, index: 0}
+	for i := 1; i < len(members); i++ {
+		root.insert(members[i].Hashes.Perceptual, i)
+	}
+
+	assigned := make([]bool, len(members))
+	var clusters []Cluster
+	for i, rep := range members {
+		if assigned[i] {
+			continue
+		}
+		assigned[i] = true
+
+		neighbours := root.within(rep.Hashes.Perceptual, maxDistance, nil)
+		sort.Ints(neighbours)
+
+		group := []Member{rep}
+		for _, j := range neighbours {
+			if !assigned[j] {
+				assigned[j] = true
+				group = append(group, members[j])
+			}
+		}
+		if len(group) < 2 {
+			continue
+		}
+
+		for k := range group {
+			group[k].AverageDistance = Distance(rep.Hashes.Average, group[k].Hashes.Average)
+			group[k].DifferenceDistance = Distance(rep.Hashes.Difference, group[k].Hashes.Difference)
+			group[k].PerceptualDistance = Distance(rep.Hashes.Perceptual, group[k].Hashes.Perceptual)
+		}
+		clusters = append(clusters, Cluster{Members: group})
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Members) != len(clusters[j].Members) {
+			return len(clusters[i].Members) > len(clusters[j].Members)
+		}
+		return clusters[i].Members[0].Path < clusters[j].Members[0].Path
+	})
+
+	return clusters
+}
+
+// computeAll hashes files on a worker pool, skipping anything that fails to decode
+func computeAll(files []scanner.FileInfo) []Member {
+	workChan := make(chan scanner.FileInfo, 100)
+	resultChan := make(chan Member, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range workChan {
+				h, err := Compute(f.Path)
+				if err != nil {
+					continue
+				}
+				resultChan <- Member{Path: f.Path, Size: f.Size, Hashes: h}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	go func() {
+		for _, f := range files {
+			workChan <- f
+		}
+		close(workChan)
+	}()
+
+	var members []Member
+	for m := range resultChan {
+		members = append(members, m)
+	}
+
+	// Keep results independent of worker scheduling
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Path < members[j].Path
+	})
+	return members
+}
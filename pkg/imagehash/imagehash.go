@@ -0,0 +1,192 @@
+package imagehash
+
+import (
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"math/bits"
+	"os"
+	"sort"
+)
+
+// Hashes holds the three 64-bit perceptual fingerprints of an image
+type Hashes struct {
+	Average    uint64
+	Difference uint64
+	Perceptual uint64
+}
+
+const (
+	hashSide = 8
+	dctSide  = 32
+)
+
+// Compute decodes the image at path and returns its perceptual hashes
+func Compute(path string) (Hashes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Hashes{}, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return Hashes{}, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	return FromImage(img), nil
+}
+
+// FromImage computes the perceptual hashes of an already decoded image
+func FromImage(img image.Image) Hashes {
+	return Hashes{
+		Average:    averageHash(img),
+		Difference: differenceHash(img),
+		Perceptual: dctHash(img),
+	}
+}
+
+// Distance returns the Hamming distance between two 64-bit hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// Similarity converts a Hamming distance into a 0..1 score
+func Similarity(distance int) float64 {
+	return 1 - float64(distance)/64
+}
+
+// averageHash sets a bit for every pixel of an 8x8 thumbnail brighter than the mean
+func averageHash(img image.Image) uint64 {
+	pixels := grayscale(img, hashSide, hashSide)
+
+	var sum float64
+	for _, p := range pixels {
+		sum += p
+	}
+	mean := sum / float64(len(pixels))
+
+	var hash uint64
+	for i, p := range pixels {
+		if p > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// differenceHash sets a bit when a pixel is brighter than its right neighbour
+func differenceHash(img image.Image) uint64 {
+	pixels := grayscale(img, hashSide+1, hashSide)
+
+	var hash uint64
+	bit := 0
+	for y := 0; y < hashSide; y++ {
+		for x := 0; x < hashSide; x++ {
+			row := y * (hashSide + 1)
+			if pixels[row+x] > pixels[row+x+1] {
+				hash |= 1 << uint(bit)
+			}
+			bit++
+		}
+	}
+	return hash
+}
+
+// dctHash keeps the lowest 8x8 frequencies of a 32x32 DCT and compares them
+// to their median, which survives resizing and recompression well
+func dctHash(img image.Image) uint64 {
+	pixels := grayscale(img, dctSide, dctSide)
+	coeffs := dct2D(pixels, dctSide)
+
+	low := make([]float64, 0, hashSide*hashSide)
+	for y := 0; y < hashSide; y++ {
+		for x := 0; x < hashSide; x++ {
+			low = append(low, coeffs[y*dctSide+x])
+		}
+	}
+
+	// The DC term only reflects overall brightness so it is left out of the median
+	sorted := append([]float64(nil), low[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for i, c := range low {
+		if c > median {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash
+}
+
+// dct2D computes the type-II discrete cosine transform of an n x n matrix
+func dct2D(pixels []float64, n int) []float64 {
+	cos := make([]float64, n*n)
+	for k := 0; k < n; k++ {
+		for i := 0; i < n; i++ {
+			cos[k*n+i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+
+	rows := make([]float64, n*n)
+	for y := 0; y < n; y++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for x := 0; x < n; x++ {
+				sum += pixels[y*n+x] * cos[k*n+x]
+			}
+			rows[y*n+k] = sum
+		}
+	}
+
+	out := make([]float64, n*n)
+	for x := 0; x < n; x++ {
+		for k := 0; k < n; k++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				sum += rows[y*n+x] * cos[k*n+y]
+			}
+			out[k*n+x] = sum
+		}
+	}
+	return out
+}
+
+// grayscale box-filters img down to a w x h luminance matrix
+func grayscale(img image.Image, w, h int) []float64 {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	out := make([]float64, w*h)
+	if srcW == 0 || srcH == 0 {
+		return out
+	}
+
+	for ty := 0; ty < h; ty++ {
+		y0 := bounds.Min.Y + ty*srcH/h
+		y1 := bounds.Min.Y + (ty+1)*srcH/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < w; tx++ {
+			x0 := bounds.Min.X + tx*srcW/w
+			x1 := bounds.Min.X + (tx+1)*srcW/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					r, g, b, _ := img.At(x, y).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+				}
+			}
+			out[ty*w+tx] = sum / float64((x1-x0)*(y1-y0))
+		}
+	}
+	return out
+}
@@ -0,0 +1,227 @@
+package imagehash
+
+import (
+	"bytes"
+	"dupe-file-checker/pkg/scanner"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testImage draws a smooth pattern that survives scaling and recompression
+func testImage(w, h int, inverted bool) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8((x*255/w + y*128/h) / 2)
+			if x > w/2 && y < h/2 {
+				v = 255 - v
+			}
+			if inverted {
+				v = 255 - v
+			}
+			img.Set(x, y, color.RGBA{v, v / 2, 255 - v, 255})
+		}
+	}
+	return img
+}
+
+func writeImage(t *testing.T, path string, img image.Image) scanner.FileInfo {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+
+	if filepath.Ext(path) == ".png" {
+		err = png.Encode(f, img)
+	} else {
+		err = jpeg.Encode(f, img, &jpeg.Options{Quality: 60})
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode %s: %v", path, err)
+	}
+
+	info, _ := f.Stat()
+	return scanner.FileInfo{Path: path, Size: info.Size()}
+}
+
+func TestDistance(t *testing.T) {
+	if d := Distance(0, 0); d != 0 {
+		t.Errorf("Distance(0, 0) = %d; want 0", d)
+	}
+	if d := Distance(0, 0xFF); d != 8 {
+		t.Errorf("Distance(0, 0xFF) = %d; want 8", d)
+	}
+	if s := Similarity(16); s != 0.75 {
+		t.Errorf("Similarity(16) = %f; want 0.75", s)
+	}
+}
+
+func TestHashesStableAcrossResize(t *testing.T) {
+	large := FromImage(testImage(256, 192, false))
+	small := FromImage(testImage(64, 48, false))
+
+	if d := Distance(large.Perceptual, small.Perceptual); d > 6 {
+		t.Errorf("Perceptual distance after resize = %d; want <= 6", d)
+	}
+	if d := Distance(large.Difference, small.Difference); d > 6 {
+		t.Errorf("Difference distance after resize = %d; want <= 6", d)
+	}
+
+	other := FromImage(testImage(256, 192, true))
+	if d := Distance(large.Perceptual, other.Perceptual); d < 20 {
+		t.Errorf("Perceptual distance to different image = %d; want >= 20", d)
+	}
+}
+
+func TestFindClusters(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	files := []scanner.FileInfo{
+		writeImage(t, filepath.Join(tmpDir, "original.png"), testImage(320, 240, false)),
+		writeImage(t, filepath.Join(tmpDir, "resized.jpg"), testImage(160, 120, false)),
+		writeImage(t, filepath.Join(tmpDir, "other.png"), testImage(320, 240, true)),
+	}
+
+	notImage := filepath.Join(tmpDir, "notes.png")
+	os.WriteFile(notImage, []byte("not really a png"), 0644)
+	files = append(files, scanner.FileInfo{Path: notImage, Size: 16})
+
+	clusters := FindClusters(files, 10)
+	if len(clusters) != 1 {
+		t.Fatalf("Expected 1 cluster, got %d", len(clusters))
+	}
+
+	members := clusters[0].Members
+	if len(members) != 2 {
+		t.Fatalf("Expected 2 cluster members, got %d", len(members))
+	}
+	if members[0].PerceptualDistance != 0 {
+		t.Errorf("Representative distance = %d; want 0", members[0].PerceptualDistance)
+	}
+	if members[1].Similarity() < 0.8 {
+		t.Errorf("Resized similarity = %f; want >= 0.8", members[1].Similarity())
+	}
+}
+
+func TestClusterDoesNotChain(t *testing.T) {
+	// a-b and b-c are 8 bits apart, a-c 16: with a limit of 10, c must not
+	// join a's cluster through b
+	members := []Member{
+		{Path: "a", Size: 300, Hashes: Hashes{Perceptual: 0}},
+		{Path: "b", Size: 200, Hashes: Hashes{Perceptual: 0xff}},
+		{Path: "c", Size: 100, Hashes: Hashes{Perceptual: 0xffff}},
+	}
+
+	clusters := cluster(members, 10)
+	if len(clusters) != 1 || len(clusters[0].Members) != 2 {
+		t.Fatalf("Expected a single cluster of 2, got %+v", clusters)
+	}
+	for _, m := range clusters[0].Members {
+		if m.PerceptualDistance > 10 {
+			t.Errorf("%s is %d bits from the representative", m.Path, m.PerceptualDistance)
+		}
+	}
+}
+
+// encodeBMP writes a bottom-up 24-bit BMP, enough to exercise the decoder
+func encodeBMP(img image.Image) []byte {
+	b := img.Bounds()
+	stride := (b.Dx()*3 + 3) &^ 3
+	size := 54 + stride*b.Dy()
+
+	buf := make([]byte, size)
+	copy(buf, "BM")
+	binary.LittleEndian.PutUint32(buf[2:], uint32(size))
+	binary.LittleEndian.PutUint32(buf[10:], 54)
+	binary.LittleEndian.PutUint32(buf[14:], 40)
+	binary.LittleEndian.PutUint32(buf[18:], uint32(b.Dx()))
+	binary.LittleEndian.PutUint32(buf[22:], uint32(b.Dy()))
+	binary.LittleEndian.PutUint16(buf[26:], 1)
+	binary.LittleEndian.PutUint16(buf[28:], 24)
+
+	for y := 0; y < b.Dy(); y++ {
+		row := buf[54+(b.Dy()-1-y)*stride:]
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			row[x*3], row[x*3+1], row[x*3+2] = byte(bl>>8), byte(g>>8), byte(r>>8)
+		}
+	}
+	return buf
+}
+
+func TestDecodeBMPRejectsMalformedHeader(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+
+	huge := encodeBMP(img)[:54]
+	binary.LittleEndian.PutUint32(huge[18:], 0x7fffffff)
+	binary.LittleEndian.PutUint32(huge[22:], 0x7fffffff)
+	if _, _, err := image.Decode(bytes.NewReader(huge)); err == nil {
+		t.Error("Expected an error for a 0x7fffffff x 0x7fffffff header")
+	}
+
+	// A pixel offset far beyond the data must fail on the short read
+	gap := encodeBMP(img)
+	binary.LittleEndian.PutUint32(gap[10:], 0xfffffff0)
+	if _, _, err := image.Decode(bytes.NewReader(gap)); err == nil {
+		t.Error("Expected an error for a pixel offset past the end of the file")
+	}
+}
+
+func TestFindPixelMatchesAcrossFormats(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	palette := color.Palette{
+		color.RGBA{0, 0, 0, 255},
+		color.RGBA{255, 0, 0, 255},
+		color.RGBA{0, 128, 255, 255},
+		color.RGBA{255, 255, 255, 255},
+	}
+	img := image.NewPaletted(image.Rect(0, 0, 13, 7), palette)
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % len(palette))
+	}
+
+	var pngBuf, gifBuf bytes.Buffer
+	png.Encode(&pngBuf, img)
+	gif.Encode(&gifBuf, img, nil)
+
+	paths := map[string][]byte{
+		"picture.png": pngBuf.Bytes(),
+		"picture.gif": gifBuf.Bytes(),
+		"picture.bmp": encodeBMP(img),
+	}
+	var files []scanner.FileInfo
+	for name, data := range paths {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, data, 0644)
+		files = append(files, scanner.FileInfo{Path: path, Size: int64(len(data))})
+	}
+
+	// Same dimensions but different pixels must not match
+	other := image.NewPaletted(image.Rect(0, 0, 13, 7), palette)
+	files = append(files, writeImage(t, filepath.Join(tmpDir, "other.png"), other))
+
+	groups := FindPixelMatches(scanner.New(), files)
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	if len(groups[0].Files) != 3 {
+		t.Errorf("Expected 3 files, got %v", groups[0].Files)
+	}
+	if groups[0].Kind != scanner.MatchPixels {
+		t.Errorf("Kind = %q; want %q", groups[0].Kind, scanner.MatchPixels)
+	}
+	if groups[0].Details[1] != "formats: bmp, gif, png" {
+		t.Errorf("Unexpected details %v", groups[0].Details)
+	}
+}
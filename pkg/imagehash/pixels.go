@@ -0,0 +1,166 @@
+package imagehash
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// pixelFormats lists the extensions the pixel-exact mode can decode
+var pixelFormats = map[string]bool{
+	".png":  true,
+	".gif":  true,
+	".bmp":  true,
+	".jpg":  true,
+	".jpeg": true,
+}
+
+// Dimensions reads only the image header and returns width and height
+func Dimensions(path string) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("decode %s: %w", path, err)
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// PixelHash decodes the image at path to canonical 8-bit RGBA and hashes its
+// dimensions and pixels, so the same picture hashes the same in any format
+func PixelHash(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var frames []image.Image
+	if strings.ToLower(filepath.Ext(path)) == ".gif" {
+		// Animated GIFs only match if every frame matches
+		anim, err := gif.DecodeAll(f)
+		if err != nil {
+			return 0, fmt.Errorf("decode %s: %w", path, err)
+		}
+		for _, frame := range anim.Image {
+			frames = append(frames, frame)
+		}
+	} else {
+		img, _, err := image.Decode(f)
+		if err != nil {
+			return 0, fmt.Errorf("decode %s: %w", path, err)
+		}
+		frames = append(frames, img)
+	}
+
+	h := xxhash.New()
+	for _, frame := range frames {
+		writePixels(h, frame)
+	}
+	return h.Sum64(), nil
+}
+
+// writePixels feeds the dimensions and canonical RGBA pixels of img into h
+func writePixels(h *xxhash.Digest, img image.Image) {
+	bounds := img.Bounds()
+	canonical := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(canonical, canonical.Bounds(), img, bounds.Min, draw.Src)
+
+	// Fully transparent pixels look the same whatever colour they carry
+	for i := 0; i < len(canonical.Pix); i += 4 {
+		if canonical.Pix[i+3] == 0 {
+			canonical.Pix[i], canonical.Pix[i+1], canonical.Pix[i+2] = 0, 0, 0
+		}
+	}
+
+	var dims [8]byte
+	binary.LittleEndian.PutUint32(dims[0:4], uint32(bounds.Dx()))
+	binary.LittleEndian.PutUint32(dims[4:8], uint32(bounds.Dy()))
+	h.Write(dims[:])
+	h.Write(canonical.Pix)
+}
+
+// FindPixelMatches groups images whose decoded pixels are identical. Like the
+// byte-level scan it stages the work: image dimensions read from the header
+// are the cheap filter, and only images sharing dimensions are fully decoded.
+func FindPixelMatches(s *scanner.Scanner, files []scanner.FileInfo) []scanner.DuplicateGroup {
+	var images []scanner.FileInfo
+	for _, f := range files {
+		if pixelFormats[strings.ToLower(filepath.Ext(f.Path))] {
+			images = append(images, f)
+		}
+	}
+
+	dimGroups := s.GroupBy(images, func(f scanner.FileInfo) (string, error) {
+		w, h, err := Dimensions(f.Path)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%dx%d", w, h), nil
+	})
+
+	var candidates []scanner.FileInfo
+	for _, group := range dimGroups {
+		candidates = append(candidates, group...)
+	}
+
+	var hashes sync.Map
+	pixelGroups := s.GroupBy(candidates, func(f scanner.FileInfo) (string, error) {
+		hash, err := PixelHash(f.Path)
+		if err != nil {
+			return "", err
+		}
+		hashes.Store(f.Path, hash)
+		return strconv.FormatUint(hash, 16), nil
+	})
+
+	var groups []scanner.DuplicateGroup
+	for _, members := range pixelGroups {
+		hash, _ := hashes.Load(members[0].Path)
+		group := scanner.NewGroup(scanner.MatchPixels, hash.(uint64), members)
+
+		w, h, _ := Dimensions(members[0].Path)
+		group.Details = append(group.Details,
+			fmt.Sprintf("%dx%d pixels", w, h),
+			"formats: "+strings.Join(formatsOf(members), ", "))
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
+
+// formatsOf lists the distinct file formats of a group
+func formatsOf(files []scanner.FileInfo) []string {
+	seen := make(map[string]bool)
+	var formats []string
+	for _, f := range files {
+		ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(f.Path)), ".")
+		if ext == "jpeg" {
+			ext = "jpg"
+		}
+		if !seen[ext] {
+			seen[ext] = true
+			formats = append(formats, ext)
+		}
+	}
+	sort.Strings(formats)
+	return formats
+}
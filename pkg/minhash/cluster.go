@@ -0,0 +1,199 @@
+package minhash
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"dupe-file-checker/pkg/textnorm"
+	"encoding/binary"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Member is one document of a cluster with its estimated similarity to the
+// cluster's first document
+type Member struct {
+	Path       string
+	Size       int64
+	Similarity float64
+}
+
+// Cluster groups documents whose estimated Jaccard similarity reaches the threshold
+type Cluster struct {
+	Members []Member
+}
+
+// shingleSize is the number of words per shingle
+const shingleSize = 3
+
+// maxSketchBytes caps how much of each document is read and shingled, so a
+// huge log or dump cannot exhaust memory; longer documents are compared by
+// their first maxSketchBytes only
+const maxSketchBytes = 4 << 20
+
+type document struct {
+	file scanner.FileInfo
+	sig  Signature
+}
+
+// FindClusters sketches every text file and groups those whose estimated
+// Jaccard similarity is at least threshold. LSH banding limits comparisons
+// to documents that share a band.
+func FindClusters(files []scanner.FileInfo, threshold float64) []Cluster {
+	return cluster(sketchAll(files), threshold)
+}
+
+// cluster groups documents around representatives, largest first. Every
+// member reaches threshold against its own representative, so clusters
+// never chain through intermediate documents.
+func cluster(docs []document, threshold float64) []Cluster {
+	if len(docs) < 2 {
+		return nil
+	}
+
+	// The largest document is most likely the most complete version
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].file.Size > docs[j].file.Size
+	})
+
+	bands, rows := Banding(threshold)
+
+	similar := make([][]int, len(docs))
+	checked := make(map[[2]int]bool)
+	for band := 0; band < bands; band++ {
+		buckets := make(map[uint64][]int)
+		for i, d := range docs {
+			h := xxhash.New()
+			var b [8]byte
+			for _, v := range d.sig[band*rows : (band+1)*rows] {
+				binary.LittleEndian.PutUint64(b[:], v)
+				h.Write(b[:])
+			}
+			key := h.Sum64()
+			buckets[key] = append(buckets[key], i)
+		}
+
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					pair := [2]int{bucket[x], bucket[y]}
+					if checked[pair] {
+						continue
+					}
+					checked[pair] = true
+
+					if Similarity(docs[pair[0]].sig, docs[pair[1]].sig) >= threshold {
+						similar[pair[0]] = append(similar[pair[0]], pair[1])
+						similar[pair[1]] = append(similar[pair[1]], pair[0])
+					}
+				}
+			}
+		}
+	}
+
+	assigned := make([]bool, len(docs))
+	var clusters []Cluster
+	for i, rep := range docs {
+		if assigned[i] {
+			continue
+		}
+		assigned[i] = true
+
+		idx := []int{i}
+		sort.Ints(similar[i])
+		for _, j := range similar[i] {
+			if !assigned[j] {
+				assigned[j] = true
+				idx = append(idx, j)
+			}
+		}
+		if len(idx) < 2 {
+			continue
+		}
+
+		var c Cluster
+		for _, j := range idx {
+			c.Members = append(c.Members, Member{
+				Path:       docs[j].file.Path,
+				Size:       docs[j].file.Size,
+				Similarity: Similarity(rep.sig, docs[j].sig),
+			})
+		}
+		clusters = append(clusters, c)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Members) != len(clusters[j].Members) {
+			return len(clusters[i].Members) > len(clusters[j].Members)
+		}
+		return clusters[i].Members[0].Path < clusters[j].Members[0].Path
+	})
+	return clusters
+}
+
+// sketchAll computes signatures for every text file on a worker pool,
+// skipping binary and unreadable files
+func sketchAll(files []scanner.FileInfo) []document {
+	workChan := make(chan scanner.FileInfo, 100)
+	resultChan := make(chan document, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range workChan {
+				if isText, err := textnorm.IsText(f.Path); err != nil || !isText {
+					continue
+				}
+				data, err := readHead(f.Path, maxSketchBytes)
+				if err != nil {
+					continue
+				}
+
+				text, _ := textnorm.Normalize(data, textnorm.Options{CollapseWhitespace: true, FoldCase: true})
+				shingles := Shingles(text, shingleSize)
+				if len(shingles) == 0 {
+					continue
+				}
+				resultChan <- document{file: f, sig: Compute(shingles)}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	go func() {
+		for _, f := range files {
+			workChan <- f
+		}
+		close(workChan)
+	}()
+
+	var docs []document
+	for d := range resultChan {
+		docs = append(docs, d)
+	}
+
+	// Keep results independent of worker scheduling
+	sort.Slice(docs, func(i, j int) bool {
+		return docs[i].file.Path < docs[j].file.Path
+	})
+	return docs
+}
+
+// readHead reads at most limit bytes from the start of a file
+func readHead(path string, limit int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, limit))
+}
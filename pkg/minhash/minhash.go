@@ -0,0 +1,86 @@
+package minhash
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// NumHashes is the signature length; estimates are within ~±0.09 at 95%
+const NumHashes = 128
+
+// Signature is the MinHash sketch of a document's shingle set
+type Signature [NumHashes]uint64
+
+// permutations holds odd multipliers and offsets for the NumHashes
+// universal hash functions; a fixed seed keeps signatures comparable
+var permutations = func() [NumHashes][2]uint64 {
+	var p [NumHashes][2]uint64
+	r := rand.New(rand.NewSource(0x4d696e48617368))
+	for i := range p {
+		p[i] = [2]uint64{r.Uint64() | 1, r.Uint64()}
+	}
+	return p
+}()
+
+// Shingles splits text into overlapping k-word shingles and hashes each one.
+// Texts shorter than k words yield a single shingle of the whole text.
+func Shingles(text string, k int) map[uint64]bool {
+	words := strings.Fields(text)
+	shingles := make(map[uint64]bool)
+	if len(words) == 0 {
+		return shingles
+	}
+	if len(words) < k {
+		shingles[xxhash.Sum64String(strings.Join(words, " "))] = true
+		return shingles
+	}
+	for i := 0; i+k <= len(words); i++ {
+		shingles[xxhash.Sum64String(strings.Join(words[i:i+k], " "))] = true
+	}
+	return shingles
+}
+
+// Compute builds the MinHash signature of a shingle set
+func Compute(shingles map[uint64]bool) Signature {
+	var sig Signature
+	for i := range sig {
+		sig[i] = math.MaxUint64
+	}
+	for s := range shingles {
+		for i, p := range permutations {
+			if h := s*p[0] + p[1]; h < sig[i] {
+				sig[i] = h
+			}
+		}
+	}
+	return sig
+}
+
+// Similarity estimates the Jaccard similarity of the two shingle sets
+func Similarity(a, b Signature) float64 {
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / NumHashes
+}
+
+// Banding picks the LSH rows per band for a Jaccard threshold. The chance two
+// documents become candidates is 1-(1-s^r)^b; the curve's midpoint
+// (1/b)^(1/r) is kept at or just below the threshold so few true matches are
+// lost, and candidates are verified against the threshold afterwards.
+func Banding(threshold float64) (bands, rows int) {
+	bands, rows = NumHashes, 1
+	for r := 1; r <= NumHashes; r *= 2 {
+		b := NumHashes / r
+		if math.Pow(1/float64(b), 1/float64(r)) <= threshold*0.9 {
+			bands, rows = b, r
+		}
+	}
+	return bands, rows
+}
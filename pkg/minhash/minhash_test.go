@@ -0,0 +1,136 @@
+package minhash
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// paragraph generates deterministic prose with n distinct sentences
+func paragraph(prefix string, n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%s sentence number %d talks about topic %d in some detail.\n", prefix, i, i*7%13)
+	}
+	return b.String()
+}
+
+func TestSimilarityEstimate(t *testing.T) {
+	a := Shingles(paragraph("The", 40), 3)
+	b := Shingles(paragraph("The", 40)+paragraph("Extra", 4), 3)
+
+	inter := 0
+	for s := range a {
+		if b[s] {
+			inter++
+		}
+	}
+	exact := float64(inter) / float64(len(a)+len(b)-inter)
+
+	est := Similarity(Compute(a), Compute(b))
+	if math.Abs(est-exact) > 0.15 {
+		t.Errorf("Estimated similarity %f too far from exact %f", est, exact)
+	}
+	if Similarity(Compute(a), Compute(a)) != 1 {
+		t.Error("Expected identical sets to have similarity 1")
+	}
+}
+
+func TestBanding(t *testing.T) {
+	for _, threshold := range []float64{0.5, 0.8, 0.95} {
+		bands, rows := Banding(threshold)
+		if bands*rows != NumHashes {
+			t.Errorf("Banding(%f) = %d x %d; want %d hashes", threshold, bands, rows, NumHashes)
+		}
+		if mid := math.Pow(1/float64(bands), 1/float64(rows)); mid > threshold {
+			t.Errorf("Banding(%f) midpoint %f above threshold", threshold, mid)
+		}
+	}
+}
+
+func TestFindClusters(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	draft := paragraph("The", 60)
+	edited := strings.Replace(draft, "sentence number 12 talks", "sentence number 12 speaks", 1)
+	contents := map[string]string{
+		"draft.txt":    draft,
+		"final.txt":    edited,
+		"unrelated.md": strings.Repeat("Completely different notes about gardening, soil and weather.\n", 30),
+		"image.bin":    "\x00\x01\x02 binary",
+	}
+
+	var files []scanner.FileInfo
+	for name, content := range contents {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		files = append(files, scanner.FileInfo{Path: path, Size: int64(len(content))})
+	}
+
+	clusters := FindClusters(files, 0.8)
+	if len(clusters) != 1 {
+		t.Fatalf("Expected 1 cluster, got %d", len(clusters))
+	}
+	if len(clusters[0].Members) != 2 {
+		t.Fatalf("Expected 2 members, got %d", len(clusters[0].Members))
+	}
+	if clusters[0].Members[0].Similarity != 1 {
+		t.Errorf("Representative similarity = %f; want 1", clusters[0].Members[0].Similarity)
+	}
+	if s := clusters[0].Members[1].Similarity; s < 0.8 || s == 1 {
+		t.Errorf("Edited draft similarity = %f; want between 0.8 and 1", s)
+	}
+}
+
+func TestClusterDoesNotChain(t *testing.T) {
+	// a and b share 108 of 128 values, b and c too, but a and c only 88:
+	// at 0.8, c must not join a's cluster through b
+	var a Signature
+	for i := range a {
+		a[i] = uint64(i)
+	}
+	b, c := a, a
+	for i := 0; i < 20; i++ {
+		b[i] += 1000
+		c[i] += 1000
+		c[20+i] += 1000
+	}
+
+	docs := []document{
+		{file: scanner.FileInfo{Path: "a", Size: 300}, sig: a},
+		{file: scanner.FileInfo{Path: "b", Size: 200}, sig: b},
+		{file: scanner.FileInfo{Path: "c", Size: 100}, sig: c},
+	}
+	clusters := cluster(docs, 0.8)
+	if len(clusters) != 1 || len(clusters[0].Members) != 2 {
+		t.Fatalf("Expected a single cluster of 2, got %+v", clusters)
+	}
+	for _, m := range clusters[0].Members {
+		if m.Similarity < 0.8 {
+			t.Errorf("%s has similarity %f to the representative", m.Path, m.Similarity)
+		}
+	}
+}
+
+func TestFindClustersCapsDocumentSize(t *testing.T) {
+	tmpDir := t.TempDir()
+	shared := paragraph("Shared", maxSketchBytes/40)
+
+	var files []scanner.FileInfo
+	for _, name := range []string{"a.log", "b.log"} {
+		content := shared + paragraph("Tail of "+name, maxSketchBytes/40)
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		files = append(files, scanner.FileInfo{Path: path, Size: int64(len(content))})
+	}
+
+	// Only the shared start is sketched, so the differing tails are ignored
+	clusters := FindClusters(files, 0.9)
+	if len(clusters) != 1 || len(clusters[0].Members) != 2 {
+		t.Fatalf("Expected a single cluster of 2, got %+v", clusters)
+	}
+}
@@ -0,0 +1,128 @@
+package overlap
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"path/filepath"
+	"sort"
+)
+
+// Pair describes how much content two directories have in common
+type Pair struct {
+	DirA string
+	DirB string
+
+	// FilesA and FilesB count the distinct contents held by each directory
+	FilesA int
+	FilesB int
+	Shared int
+
+	// Jaccard is shared / union; ContainmentA is the share of DirA found in
+	// DirB and ContainmentB the share of DirB found in DirA
+	Jaccard      float64
+	ContainmentA float64
+	ContainmentB float64
+
+	// ReclaimBytes is what merging both directories into one would free
+	ReclaimBytes int64
+}
+
+// dirContent tracks the distinct contents of a single directory
+type dirContent struct {
+	distinct int
+	copies   map[uint64]int // duplicate group hash -> copies in this directory
+}
+
+// Analyze compares every pair of directories that share at least one
+// confirmed duplicate and returns those whose Jaccard similarity or either
+// containment reaches threshold, largest reclaimable size first
+func Analyze(files []scanner.FileInfo, groups []scanner.DuplicateGroup, threshold float64) []Pair {
+	groupOf := make(map[string]int)
+	for i, group := range groups {
+		for _, path := range group.Files {
+			groupOf[path] = i
+		}
+	}
+
+	dirs := make(map[string]*dirContent)
+	for _, f := range files {
+		dir := filepath.Dir(f.Path)
+		dc := dirs[dir]
+		if dc == nil {
+			dc = &dirContent{copies: make(map[uint64]int)}
+			dirs[dir] = dc
+		}
+
+		i, ok := groupOf[f.Path]
+		if !ok {
+			// Unique content always counts as its own item
+			dc.distinct++
+			continue
+		}
+		hash := groups[i].Hash
+		if dc.copies[hash] == 0 {
+			dc.distinct++
+		}
+		dc.copies[hash]++
+	}
+
+	type key struct{ a, b string }
+	shared := make(map[key][]int)
+	for i, group := range groups {
+		var groupDirs []string
+		seen := make(map[string]bool)
+		for _, path := range group.Files {
+			dir := filepath.Dir(path)
+			if !seen[dir] && dirs[dir] != nil {
+				seen[dir] = true
+				groupDirs = append(groupDirs, dir)
+			}
+		}
+		sort.Strings(groupDirs)
+
+		for x := 0; x < len(groupDirs); x++ {
+			for y := x + 1; y < len(groupDirs); y++ {
+				k := key{groupDirs[x], groupDirs[y]}
+				shared[k] = append(shared[k], i)
+			}
+		}
+	}
+
+	var pairs []Pair
+	for k, groupIdx := range shared {
+		a, b := dirs[k.a], dirs[k.b]
+		p := Pair{
+			DirA:   k.a,
+			DirB:   k.b,
+			FilesA: a.distinct,
+			FilesB: b.distinct,
+			Shared: len(groupIdx),
+		}
+
+		for _, i := range groupIdx {
+			hash := groups[i].Hash
+			// Merging keeps a single copy of each shared content
+			p.ReclaimBytes += groups[i].Size * int64(a.copies[hash]+b.copies[hash]-1)
+		}
+
+		union := p.FilesA + p.FilesB - p.Shared
+		p.Jaccard = float64(p.Shared) / float64(union)
+		p.ContainmentA = float64(p.Shared) / float64(p.FilesA)
+		p.ContainmentB = float64(p.Shared) / float64(p.FilesB)
+
+		if p.Jaccard >= threshold || p.ContainmentA >= threshold || p.ContainmentB >= threshold {
+			pairs = append(pairs, p)
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].ReclaimBytes != pairs[j].ReclaimBytes {
+			return pairs[i].ReclaimBytes > pairs[j].ReclaimBytes
+		}
+		if pairs[i].DirA != pairs[j].DirA {
+			return pairs[i].DirA < pairs[j].DirA
+		}
+		return pairs[i].DirB < pairs[j].DirB
+	})
+
+	return pairs
+}
@@ -0,0 +1,85 @@
+package overlap
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"path/filepath"
+	"testing"
+)
+
+func fileList(paths ...string) []scanner.FileInfo {
+	var files []scanner.FileInfo
+	for _, p := range paths {
+		files = append(files, scanner.FileInfo{Path: p})
+	}
+	return files
+}
+
+func TestAnalyzeBackupWithNewFiles(t *testing.T) {
+	backup := filepath.Join("data", "backup")
+	current := filepath.Join("data", "current")
+
+	files := fileList(
+		filepath.Join(backup, "a.txt"),
+		filepath.Join(backup, "b.txt"),
+		filepath.Join(current, "a.txt"),
+		filepath.Join(current, "b.txt"),
+		filepath.Join(current, "new1.txt"),
+		filepath.Join(current, "new2.txt"),
+	)
+	groups := []scanner.DuplicateGroup{
+		{Hash: 1, Size: 100, Files: []string{filepath.Join(backup, "a.txt"), filepath.Join(current, "a.txt")}},
+		{Hash: 2, Size: 50, Files: []string{filepath.Join(backup, "b.txt"), filepath.Join(current, "b.txt")}},
+	}
+
+	pairs := Analyze(files, groups, 0.5)
+	if len(pairs) != 1 {
+		t.Fatalf("Expected 1 pair, got %d", len(pairs))
+	}
+
+	p := pairs[0]
+	if p.DirA != backup || p.DirB != current {
+		t.Errorf("Unexpected pair %s <-> %s", p.DirA, p.DirB)
+	}
+	if p.Shared != 2 {
+		t.Errorf("Shared = %d; want 2", p.Shared)
+	}
+	if p.Jaccard != 0.5 {
+		t.Errorf("Jaccard = %f; want 0.5", p.Jaccard)
+	}
+	if p.ContainmentA != 1 {
+		t.Errorf("ContainmentA = %f; want 1", p.ContainmentA)
+	}
+	if p.ContainmentB != 0.5 {
+		t.Errorf("ContainmentB = %f; want 0.5", p.ContainmentB)
+	}
+	if p.ReclaimBytes != 150 {
+		t.Errorf("ReclaimBytes = %d; want 150", p.ReclaimBytes)
+	}
+}
+
+func TestAnalyzeBelowThreshold(t *testing.T) {
+	files := fileList("x/a", "x/b", "x/c", "x/d", "y/a", "y/e", "y/f", "y/g")
+	groups := []scanner.DuplicateGroup{
+		{Hash: 1, Size: 10, Files: []string{"x/a", "y/a"}},
+	}
+
+	if pairs := Analyze(files, groups, 0.5); len(pairs) != 0 {
+		t.Errorf("Expected no pairs above threshold, got %d", len(pairs))
+	}
+}
+
+func TestAnalyzeRanksByReclaimableBytes(t *testing.T) {
+	files := fileList("a/1", "b/1", "c/2", "d/2")
+	groups := []scanner.DuplicateGroup{
+		{Hash: 1, Size: 10, Files: []string{"a/1", "b/1"}},
+		{Hash: 2, Size: 1000, Files: []string{"c/2", "d/2"}},
+	}
+
+	pairs := Analyze(files, groups, 0)
+	if len(pairs) != 2 {
+		t.Fatalf("Expected 2 pairs, got %d", len(pairs))
+	}
+	if pairs[0].DirA != "c" || pairs[0].ReclaimBytes != 1000 {
+		t.Errorf("Expected c <-> d first, got %s <-> %s (%d bytes)", pairs[0].DirA, pairs[0].DirB, pairs[0].ReclaimBytes)
+	}
+}
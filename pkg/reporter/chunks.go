@@ -0,0 +1,27 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/chunker"
+	"fmt"
+)
+
+// PrintChunkReport prints the estimated savings of a chunk-level dedup store
+func PrintChunkReport(report chunker.Report) {
+	opts := report.Options
+	fmt.Printf("🧩 CHUNK-LEVEL DEDUP ESTIMATE (chunks %s / %s / %s min/avg/max)\n",
+		formatSize(int64(opts.MinSize)), formatSize(int64(opts.AvgSize)), formatSize(int64(opts.MaxSize)))
+	fmt.Println("Per top-level directory, each deduplicated on its own:")
+
+	for _, dir := range report.Dirs {
+		fmt.Printf("├─ %s   %d files, %s -> %s (%s saved, %s)\n",
+			dir.Path, dir.Files, formatSize(dir.TotalBytes), formatSize(dir.UniqueBytes),
+			formatSize(dir.SavedBytes()), formatPercent(dir.SavedRatio()))
+	}
+
+	overall := report.Overall
+	fmt.Printf("\nTotal: %d files in %d chunks (%d unique), %s stored as %s\n",
+		overall.Files, overall.Chunks, overall.UniqueChunks,
+		formatSize(overall.TotalBytes), formatSize(overall.UniqueBytes))
+	fmt.Printf("Chunk-level dedup would save %s (%s)\n",
+		formatSize(overall.SavedBytes()), formatPercent(overall.SavedRatio()))
+}
@@ -1,9 +1,13 @@
 package reporter
 
 import (
+	"bytes"
+	"dupe-file-checker/pkg/dedupe"
 	"dupe-file-checker/pkg/scanner"
+	"encoding/json"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -185,4 +189,124 @@ func TestAnalyzeDirectoriesSingleFiles(t *testing.T) {
 	if len(dirStats) != 0 {
 		t.Errorf("Expected 0 directories for single files, got %d", len(dirStats))
 	}
-}
\ No newline at end of file
+}
+func TestFormatPercent(t *testing.T) {
+	tests := []struct {
+		ratio    float64
+		expected string
+	}{
+		{0, "0%"},
+		{0.5, "50%"},
+		{0.333, "33%"},
+		{1, "100%"},
+	}
+
+	for _, test := range tests {
+		result := formatPercent(test.ratio)
+		if result != test.expected {
+			t.Errorf("formatPercent(%f) = %s; want %s", test.ratio, result, test.expected)
+		}
+	}
+}
+
+func TestNewJSONReport(t *testing.T) {
+	groups := []scanner.DuplicateGroup{
+		scanner.NewGroup(scanner.MatchExact, 0xabc, []scanner.FileInfo{
+			{Path: "/x/a.txt", Size: 100, ModTime: 1600000000},
+			{Path: "/y/a.txt", Size: 100, ModTime: 1700000000},
+			{Path: "/z/a.txt", Size: 100, ModTime: 1700000000},
+		}),
+	}
+
+	report := NewJSONReport(map[string]string{"mode": "exact"}, []string{"/x"}, 10, groups, nil)
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if decoded["version"] != float64(JSONSchemaVersion) || decoded["schema"] != "dupe-checker/scan" {
+		t.Errorf("Missing schema version: %v", decoded)
+	}
+
+	if report.Summary != (JSONSummary{FilesScanned: 10, Groups: 1, DuplicateFiles: 2, WastedBytes: 200}) {
+		t.Errorf("Summary = %+v", report.Summary)
+	}
+	g := report.Groups[0]
+	if g.Hash != "0000000000000abc" || g.Kind != "exact" || len(g.Files) != 3 {
+		t.Errorf("Group = %+v", g)
+	}
+	if g.Files[0].ModTime == nil || g.Files[0].ModTime.Unix() != 1600000000 {
+		t.Errorf("Expected mtime on files, got %+v", g.Files[0])
+	}
+
+	// An empty scan still yields a groups array, not null
+	empty := NewJSONReport(nil, nil, 0, nil, nil)
+	buf.Reset()
+	WriteJSON(&buf, empty)
+	if !strings.Contains(buf.String(), `"groups": []`) {
+		t.Errorf("Expected empty groups array:\n%s", buf.String())
+	}
+}
+
+func TestNewJSONReportIsSorted(t *testing.T) {
+	small := scanner.NewGroup(scanner.MatchExact, 0x1, []scanner.FileInfo{
+		{Path: "/b/small", Size: 10}, {Path: "/a/small", Size: 10},
+	})
+	large := scanner.NewGroup(scanner.MatchExact, 0x2, []scanner.FileInfo{
+		{Path: "/z/large", Size: 50, ModTime: 2}, {Path: "/y/large", Size: 50, ModTime: 1},
+	})
+
+	for _, groups := range [][]scanner.DuplicateGroup{{small, large}, {large, small}} {
+		report := NewJSONReport(nil, nil, 4, groups, nil)
+		if report.Groups[0].Hash != "0000000000000002" {
+			t.Errorf("Expected the largest waste first, got %+v", report.Groups)
+		}
+		files := report.Groups[0].Files
+		if files[0].Path != "/y/large" || files[0].ModTime.Unix() != 1 {
+			t.Errorf("Expected files sorted by path with their mtimes, got %+v", files)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	groups := []scanner.DuplicateGroup{
+		scanner.NewGroup(scanner.MatchExact, 0xabc, []scanner.FileInfo{
+			{Path: "/x/b.txt", Size: 100, ModTime: 1700000000},
+			{Path: `/y/a "quoted", name.txt`, Size: 100, ModTime: 1600000000},
+		}),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, groups, dedupe.StrategyPolicy(dedupe.KeepOldest), nil, true); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "group,hash,size,path,directory,mtime,keep\r\n" +
+		"1,0000000000000abc,100,/x/b.txt,/x,2023-11-14T22:13:20Z,false\r\n" +
+		`1,0000000000000abc,100,"/y/a ""quoted"", name.txt",/y,2020-09-13T12:26:40Z,true` + "\r\n"
+	if buf.String() != want {
+		t.Errorf("WriteCSV =\n%q\nwant\n%q", buf.String(), want)
+	}
+
+	// Numbering does not depend on the order the scan found the groups in
+	larger := scanner.NewGroup(scanner.MatchExact, 0x1, []scanner.FileInfo{
+		{Path: "/z/big", Size: 500, ModTime: 1}, {Path: "/a/big", Size: 500, ModTime: 1},
+	})
+	for _, order := range [][]scanner.DuplicateGroup{{groups[0], larger}, {larger, groups[0]}} {
+		buf.Reset()
+		WriteCSV(&buf, order, dedupe.StrategyPolicy(dedupe.KeepOldest), nil, false)
+		if !strings.HasPrefix(buf.String(), "1,0000000000000001,500,/a/big,") {
+			t.Errorf("Expected the largest group first, files by path:\n%s", buf.String())
+		}
+	}
+
+	buf.Reset()
+	WriteCSV(&buf, groups, dedupe.StrategyPolicy(dedupe.KeepOldest), nil, false)
+	if strings.HasPrefix(buf.String(), "group,") {
+		t.Errorf("Expected no header row:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,56 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/scanner"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// CSVHeader names the columns written by WriteCSV
+var CSVHeader = []string{"group", "hash", "size", "path", "directory", "mtime", "keep"}
+
+// WriteCSV writes one row per file of every group, quoted per RFC 4180,
+// numbering groups in the same order as the JSON report. The keep column
+// marks the copy policy would keep; it is only set for groups dedupe can act
+// on (byte-identical files outside archives).
+func WriteCSV(w io.Writer, groups []scanner.DuplicateGroup, policy *dedupe.Policy, roots []string, header bool) error {
+	keepers := make(map[string]bool)
+	for _, gp := range dedupe.NewPlan(groups, policy, roots, nil).Groups {
+		keepers[gp.Keep] = true
+	}
+
+	cw := csv.NewWriter(w)
+	cw.UseCRLF = true
+	if header {
+		cw.Write(CSVHeader)
+	}
+
+	// Stable numbering, so filters and pivots survive a re-run
+	for i, group := range sortGroups(groups) {
+		hash := fmt.Sprintf("%016x", group.Hash)
+		for j, path := range group.Files {
+			size, mtime := group.Size, ""
+			if j < len(group.Infos) && group.Infos[j].Path == path {
+				size = group.Infos[j].Size
+				mtime = time.Unix(group.Infos[j].ModTime, 0).UTC().Format(time.RFC3339)
+			}
+			cw.Write([]string{
+				strconv.Itoa(i + 1),
+				hash,
+				strconv.FormatInt(size, 10),
+				path,
+				filepath.Dir(path),
+				mtime,
+				strconv.FormatBool(keepers[path]),
+			})
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
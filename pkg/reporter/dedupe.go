@@ -0,0 +1,75 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/dedupe"
+	"fmt"
+)
+
+// PrintDedupeSummary lists every action taken (or planned, in a dry run)
+// followed by the totals
+func PrintDedupeSummary(summary dedupe.Summary) {
+	verb := summary.Action
+	if summary.DryRun {
+		fmt.Println("🧪 DRY RUN - no files were changed")
+		verb = "would " + verb
+	}
+	fmt.Println()
+
+	lastKeep := ""
+	for _, r := range summary.Results {
+		if r.Keep != lastKeep {
+			if r.Reason != "" {
+				fmt.Printf("keep %s (%s)\n", r.Keep, r.Reason)
+			} else {
+				fmt.Printf("keep %s\n", r.Keep)
+			}
+			lastKeep = r.Keep
+		}
+		if r.Skipped {
+			fmt.Printf("  ~ %s %v\n", r.Path, r.Err)
+			continue
+		}
+		if r.Err != nil {
+			fmt.Printf("  ✗ %s %s: %v\n", verb, r.Path, r.Err)
+			continue
+		}
+		if r.Moved > 0 {
+			fmt.Printf("  - %s %s (%s moved)\n", verb, r.Path, formatSize(r.Moved))
+			continue
+		}
+		fmt.Printf("  - %s %s (%s)\n", verb, r.Path, formatSize(r.Bytes))
+	}
+
+	if len(summary.Informational) > 0 {
+		fmt.Println()
+		fmt.Println("ℹ️  Informational only, never modified:")
+		for _, info := range summary.Informational {
+			fmt.Printf("  %d copies of %s (%s):\n", len(info.Group.Files), formatSize(info.Group.Size), info.Reason)
+			for _, path := range info.Group.Files {
+				fmt.Printf("    %s\n", path)
+			}
+		}
+	}
+
+	if summary.DryRun {
+		fmt.Printf("\n%d groups, %d files to %s, %s would be reclaimed\n",
+			summary.Groups, summary.Files, summary.Action, formatSize(summary.BytesReclaimed))
+	} else {
+		fmt.Printf("\n%d groups, %d files handled (%s), %s reclaimed\n",
+			summary.Groups, summary.Files, summary.Action, formatSize(summary.BytesReclaimed))
+	}
+	if summary.BytesMoved > 0 {
+		fmt.Printf("%s moved, freed only once the moved files are deleted\n", formatSize(summary.BytesMoved))
+	}
+	if summary.Skipped > 0 {
+		fmt.Printf("%d files skipped\n", summary.Skipped)
+	}
+	if summary.Failed > 0 {
+		fmt.Printf("%d files failed\n", summary.Failed)
+	}
+}
+
+// FormatSize converts bytes to human-readable format
+func FormatSize(bytes int64) string {
+	return formatSize(bytes)
+}
@@ -0,0 +1,184 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/overlap"
+	"dupe-file-checker/pkg/scanner"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// JSONSchemaVersion is bumped whenever a field is renamed or removed;
+// adding fields does not change it
+const JSONSchemaVersion = 1
+
+// JSONReport is the document written by --format json
+type JSONReport struct {
+	Schema      string            `json:"schema"`
+	Version     int               `json:"version"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Options     map[string]string `json:"options"`
+	Roots       []string          `json:"roots"`
+	Summary     JSONSummary       `json:"summary"`
+	Groups      []JSONGroup       `json:"groups"`
+	Overlaps    []JSONOverlap     `json:"overlaps,omitempty"`
+}
+
+// JSONSummary holds the scan totals
+type JSONSummary struct {
+	FilesScanned   int   `json:"files_scanned"`
+	Groups         int   `json:"groups"`
+	DuplicateFiles int   `json:"duplicate_files"`
+	WastedBytes    int64 `json:"wasted_bytes"`
+}
+
+// JSONGroup is one DuplicateGroup
+type JSONGroup struct {
+	Hash        string     `json:"hash"`
+	Kind        string     `json:"kind"`
+	Size        int64      `json:"size"`
+	WastedBytes int64      `json:"wasted_bytes"`
+	Files       []JSONFile `json:"files"`
+	Details     []string   `json:"details,omitempty"`
+}
+
+// JSONFile is one member of a group; ModTime is omitted when the scan did
+// not record it
+type JSONFile struct {
+	Path    string     `json:"path"`
+	Size    int64      `json:"size"`
+	ModTime *time.Time `json:"mtime,omitempty"`
+}
+
+// JSONOverlap is one directory pair from --overlap
+type JSONOverlap struct {
+	DirA         string  `json:"dir_a"`
+	DirB         string  `json:"dir_b"`
+	FilesA       int     `json:"files_a"`
+	FilesB       int     `json:"files_b"`
+	Shared       int     `json:"shared"`
+	Jaccard      float64 `json:"jaccard"`
+	ContainmentA float64 `json:"containment_a"`
+	ContainmentB float64 `json:"containment_b"`
+	ReclaimBytes int64   `json:"reclaim_bytes"`
+}
+
+// NewJSONReport builds the JSON document for a scan
+func NewJSONReport(options map[string]string, roots []string, filesScanned int, groups []scanner.DuplicateGroup, pairs []overlap.Pair) JSONReport {
+	report := JSONReport{
+		Schema:      "dupe-checker/scan",
+		Version:     JSONSchemaVersion,
+		GeneratedAt: time.Now().UTC(),
+		Options:     options,
+		Groups:      []JSONGroup{},
+	}
+	report.Summary.FilesScanned = filesScanned
+
+	for _, root := range roots {
+		if abs, err := filepath.Abs(root); err == nil {
+			root = abs
+		}
+		report.Roots = append(report.Roots, root)
+	}
+
+	for _, group := range sortGroups(groups) {
+		kind := string(group.Kind)
+		if kind == "" {
+			kind = string(scanner.MatchExact)
+		}
+		jg := JSONGroup{
+			Hash:        fmt.Sprintf("%016x", group.Hash),
+			Kind:        kind,
+			Size:        group.Size,
+			WastedBytes: group.Size * int64(len(group.Files)-1),
+			Details:     group.Details,
+		}
+		for i, path := range group.Files {
+			file := JSONFile{Path: path, Size: group.Size}
+			if i < len(group.Infos) && group.Infos[i].Path == path {
+				file.Size = group.Infos[i].Size
+				mtime := time.Unix(group.Infos[i].ModTime, 0).UTC()
+				file.ModTime = &mtime
+			}
+			jg.Files = append(jg.Files, file)
+		}
+
+		report.Groups = append(report.Groups, jg)
+		report.Summary.Groups++
+		report.Summary.DuplicateFiles += len(group.Files) - 1
+		report.Summary.WastedBytes += jg.WastedBytes
+	}
+
+	for _, p := range pairs {
+		report.Overlaps = append(report.Overlaps, JSONOverlap{
+			DirA: p.DirA, DirB: p.DirB, FilesA: p.FilesA, FilesB: p.FilesB, Shared: p.Shared,
+			Jaccard: p.Jaccard, ContainmentA: p.ContainmentA, ContainmentB: p.ContainmentB,
+			ReclaimBytes: p.ReclaimBytes,
+		})
+	}
+	return report
+}
+
+// sortGroups returns the groups largest waste first, then by hash, with
+// their files sorted by path, so reports compare cleanly between runs even
+// though the scan finds groups in no particular order
+func sortGroups(groups []scanner.DuplicateGroup) []scanner.DuplicateGroup {
+	sorted := make([]scanner.DuplicateGroup, len(groups))
+	for i, group := range groups {
+		sorted[i] = sortFiles(group)
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		wa, wb := a.Size*int64(len(a.Files)-1), b.Size*int64(len(b.Files)-1)
+		if wa != wb {
+			return wa > wb
+		}
+		if a.Hash != b.Hash {
+			return a.Hash < b.Hash
+		}
+		return a.Files[0] < b.Files[0]
+	})
+	return sorted
+}
+
+// sortFiles orders a group's files by path, keeping Infos aligned. Prefix
+// groups keep their order since the complete file has to come first.
+func sortFiles(group scanner.DuplicateGroup) scanner.DuplicateGroup {
+	if group.Kind == scanner.MatchPrefix || len(group.Files) == 0 {
+		return group
+	}
+
+	order := make([]int, len(group.Files))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return group.Files[order[i]] < group.Files[order[j]]
+	})
+
+	aligned := len(group.Infos) == len(group.Files)
+	files := make([]string, len(order))
+	var infos []scanner.FileInfo
+	for i, k := range order {
+		files[i] = group.Files[k]
+		if aligned {
+			infos = append(infos, group.Infos[k])
+		}
+	}
+	group.Files = files
+	if aligned {
+		group.Infos = infos
+	}
+	return group
+}
+
+// WriteJSON writes the report as indented JSON
+func WriteJSON(w io.Writer, report JSONReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
@@ -0,0 +1,41 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"strings"
+)
+
+// PrintMatches prints groups found by a format-aware comparison mode, with
+// the kind of match and any notes on how the copies differ
+func PrintMatches(groups []scanner.DuplicateGroup) {
+	if len(groups) == 0 {
+		fmt.Println("No matches found")
+		return
+	}
+
+	// Keep kinds in first-seen order so each gets its own section
+	var kinds []scanner.MatchKind
+	byKind := make(map[scanner.MatchKind][]scanner.DuplicateGroup)
+	for _, group := range groups {
+		if _, ok := byKind[group.Kind]; !ok {
+			kinds = append(kinds, group.Kind)
+		}
+		byKind[group.Kind] = append(byKind[group.Kind], group)
+	}
+
+	for _, kind := range kinds {
+		fmt.Printf("🔗 %s\n\n", strings.ToUpper(string(kind)))
+
+		for i, group := range byKind[kind] {
+			fmt.Printf("  Group %d (%d files):\n", i+1, len(group.Files))
+			for _, file := range group.Files {
+				fmt.Printf("    - %s\n", file)
+			}
+			for _, detail := range group.Details {
+				fmt.Printf("    ! %s\n", detail)
+			}
+			fmt.Println()
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/overlap"
+	"fmt"
+)
+
+// PrintOverlaps prints directory pairs that hold mostly the same content
+func PrintOverlaps(pairs []overlap.Pair) {
+	fmt.Println("🔀 OVERLAPPING DIRECTORIES")
+
+	if len(pairs) == 0 {
+		fmt.Println("No overlapping directories found")
+		fmt.Println()
+		return
+	}
+
+	for _, p := range pairs {
+		fmt.Printf("├─ %s <-> %s   %d shared files (%s reclaimable)\n",
+			p.DirA, p.DirB, p.Shared, formatSize(p.ReclaimBytes))
+		fmt.Printf("│    jaccard %s, first %s contained (%d files), second %s contained (%d files)\n",
+			formatPercent(p.Jaccard), formatPercent(p.ContainmentA), p.FilesA,
+			formatPercent(p.ContainmentB), p.FilesB)
+	}
+	fmt.Println()
+}
+
+// formatPercent renders a 0..1 ratio as a percentage
+func formatPercent(ratio float64) string {
+	return fmt.Sprintf("%.0f%%", ratio*100)
+}
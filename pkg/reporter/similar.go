@@ -0,0 +1,57 @@
+package reporter
+
+import (
+	"dupe-file-checker/pkg/imagehash"
+	"dupe-file-checker/pkg/minhash"
+	"fmt"
+)
+
+// PrintImageClusters prints groups of visually similar images with their
+// distance to the first image of each group
+func PrintImageClusters(clusters []imagehash.Cluster) {
+	if len(clusters) == 0 {
+		fmt.Println("No similar images found")
+		return
+	}
+
+	fmt.Println("🖼️  SIMILAR IMAGES")
+	fmt.Println()
+
+	for i, cluster := range clusters {
+		fmt.Printf("Cluster %d (%d images):\n", i+1, len(cluster.Members))
+		for j, m := range cluster.Members {
+			if j == 0 {
+				fmt.Printf("  * %s (%s, reference)\n", m.Path, formatSize(m.Size))
+				continue
+			}
+			fmt.Printf("  - %s (%s, %s similar; distance avg %d, diff %d, dct %d)\n",
+				m.Path, formatSize(m.Size), formatPercent(m.Similarity()),
+				m.AverageDistance, m.DifferenceDistance, m.PerceptualDistance)
+		}
+		fmt.Println()
+	}
+}
+
+// PrintTextClusters prints groups of near-identical documents with their
+// estimated similarity to the first document of each group
+func PrintTextClusters(clusters []minhash.Cluster) {
+	if len(clusters) == 0 {
+		fmt.Println("No similar documents found")
+		return
+	}
+
+	fmt.Println("📝 SIMILAR DOCUMENTS")
+	fmt.Println()
+
+	for i, cluster := range clusters {
+		fmt.Printf("Cluster %d (%d documents):\n", i+1, len(cluster.Members))
+		for j, m := range cluster.Members {
+			if j == 0 {
+				fmt.Printf("  * %s (%s, reference)\n", m.Path, formatSize(m.Size))
+				continue
+			}
+			fmt.Printf("  - %s (%s, ~%s similar)\n", m.Path, formatSize(m.Size), formatPercent(m.Similarity))
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,254 @@
+package review
+
+import (
+	"bufio"
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/reporter"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Decision is what the user chose to do with the non-kept copies of a group
+type Decision string
+
+const (
+	Skip   Decision = "skip"
+	Delete Decision = "delete"
+	Link   Decision = "link"
+)
+
+// Queue holds the groups the user decided on, split by what to do with them
+type Queue struct {
+	Delete dedupe.Plan
+	Link   dedupe.Plan
+}
+
+// Len returns the number of files the queue would act on
+func (q Queue) Len() int {
+	n := 0
+	for _, plan := range []dedupe.Plan{q.Delete, q.Link} {
+		for _, gp := range plan.Groups {
+			n += len(gp.Remove)
+		}
+	}
+	return n
+}
+
+// Reviewer walks through a plan one group at a time, reading single key
+// presses from in. Nothing is acted on; the result is a queue the caller
+// executes once the user confirms.
+type Reviewer struct {
+	in    *bufio.Reader
+	out   io.Writer
+	rules map[string]Decision
+	queue Queue
+}
+
+// New creates a reviewer. in is expected to be a terminal in raw mode, but
+// line-buffered input works too since whitespace is ignored.
+func New(in io.Reader, out io.Writer) *Reviewer {
+	return &Reviewer{in: bufio.NewReader(in), out: out, rules: make(map[string]Decision)}
+}
+
+// Run reviews every group of plan in order (NewPlan sorts by largest waste
+// first) and asks for confirmation at the end. Declining returns an empty queue.
+func (r *Reviewer) Run(plan dedupe.Plan) (Queue, error) {
+	total := len(plan.Groups)
+	for i, gp := range plan.Groups {
+		dir := ruleDir(gp)
+		if decision, ok := r.rules[dir]; ok {
+			fmt.Fprintf(r.out, "Group %d/%d: %s (same as the rest of %s)\n", i+1, total, decision, dir)
+			r.add(gp, decision)
+			continue
+		}
+
+		done, err := r.reviewGroup(gp, i+1, total)
+		if err != nil {
+			return Queue{}, err
+		}
+		if done {
+			break
+		}
+	}
+
+	return r.confirm()
+}
+
+// reviewGroup handles the keys for one group and reports whether the user
+// asked to finish early
+func (r *Reviewer) reviewGroup(gp dedupe.GroupPlan, n, total int) (bool, error) {
+	files := append([]string{gp.Keep}, gp.Remove...)
+	keep := make([]bool, len(files))
+	keep[0] = true
+	dir := ruleDir(gp)
+
+	r.show(gp, files, keep, n, total)
+	for {
+		key, err := r.key()
+		if err != nil {
+			return false, err
+		}
+
+		switch {
+		case key >= '1' && key <= '9' && int(key-'0') <= len(files):
+			keep[key-'1'] = !keep[key-'1']
+			r.show(gp, files, keep, n, total)
+			continue
+		case key == '?':
+			r.help(len(files), dir)
+			continue
+		case key == 'q' || key == 4: // Ctrl-D
+			fmt.Fprintln(r.out, "Review finished early")
+			return true, nil
+		}
+
+		var decision Decision
+		switch key {
+		case 'd', 'D':
+			decision = Delete
+		case 'l', 'L':
+			decision = Link
+		case 's', 'S':
+			decision = Skip
+		default:
+			fmt.Fprintln(r.out, "Unknown key, press ? for help")
+			continue
+		}
+
+		if decision != Skip && !slices.Contains(keep, true) {
+			fmt.Fprintln(r.out, "Keep at least one copy (toggle with the number keys)")
+			continue
+		}
+		if key >= 'A' && key <= 'Z' {
+			r.rules[dir] = decision
+		}
+
+		r.add(regroup(gp, files, keep), decision)
+		fmt.Fprintf(r.out, "→ %s\n\n", decision)
+		return false, nil
+	}
+}
+
+// key returns the next non-whitespace key press; end of input finishes the review
+func (r *Reviewer) key() (byte, error) {
+	for {
+		b, err := r.in.ReadByte()
+		if err == io.EOF {
+			return 'q', nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\n' && b != '\r' && b != '\t' {
+			return b, nil
+		}
+	}
+}
+
+func (r *Reviewer) add(gp dedupe.GroupPlan, decision Decision) {
+	if len(gp.Remove) == 0 {
+		return
+	}
+	switch decision {
+	case Delete:
+		r.queue.Delete.Groups = append(r.queue.Delete.Groups, gp)
+	case Link:
+		r.queue.Link.Groups = append(r.queue.Link.Groups, gp)
+	}
+}
+
+func (r *Reviewer) show(gp dedupe.GroupPlan, files []string, keep []bool, n, total int) {
+	removed := 0
+	for _, k := range keep {
+		if !k {
+			removed++
+		}
+	}
+
+	fmt.Fprintf(r.out, "Group %d/%d: %d copies of %s, %s reclaimable\n",
+		n, total, len(files), reporter.FormatSize(gp.Group.Size), reporter.FormatSize(gp.Group.Size*int64(removed)))
+	for i, path := range files {
+		label := "remove"
+		if keep[i] {
+			label = "keep"
+		}
+		mtime := time.Unix(dedupe.ScanModTime(gp.Group, path), 0).Format("2006-01-02 15:04")
+		fmt.Fprintf(r.out, "  %d %-6s  %s  %s\n", i+1, label, mtime, path)
+	}
+	if gp.Reason != "" {
+		fmt.Fprintf(r.out, "  (policy: %s)\n", gp.Reason)
+	}
+	fmt.Fprintf(r.out, "[1-%d] toggle keep  d delete  l link  s skip  D/L/S rest of %s  q finish  ? help\n", min(len(files), 9), ruleDir(gp))
+}
+
+func (r *Reviewer) help(files int, dir string) {
+	fmt.Fprintf(r.out, `  1-%d   toggle whether that copy is kept
+  d     delete the copies marked remove
+  l     replace the copies marked remove with links to the first kept copy
+  s     leave this group alone
+  D/L/S do the same for every later group with copies in %s
+  q     stop reviewing and confirm what is queued so far
+`, min(files, 9), dir)
+}
+
+// confirm lists the queued work and asks before anything happens
+func (r *Reviewer) confirm() (Queue, error) {
+	if r.queue.Len() == 0 {
+		fmt.Fprintln(r.out, "Nothing queued")
+		return Queue{}, nil
+	}
+
+	fmt.Fprintln(r.out, "Queued:")
+	for _, q := range []struct {
+		verb string
+		plan dedupe.Plan
+	}{{"delete", r.queue.Delete}, {"link", r.queue.Link}} {
+		for _, gp := range q.plan.Groups {
+			for _, path := range gp.Remove {
+				fmt.Fprintf(r.out, "  %s %s (keep %s)\n", q.verb, path, gp.Keep)
+			}
+		}
+	}
+	fmt.Fprintf(r.out, "Apply %d actions? [y/N] ", r.queue.Len())
+
+	key, err := r.key()
+	if err != nil {
+		return Queue{}, err
+	}
+	fmt.Fprintln(r.out)
+	if key != 'y' && key != 'Y' {
+		fmt.Fprintln(r.out, "Cancelled, nothing was changed")
+		return Queue{}, nil
+	}
+	return r.queue, nil
+}
+
+// ruleDir is the directory a D/L/S rule applies to: where the first copy
+// proposed for removal lives
+func ruleDir(gp dedupe.GroupPlan) string {
+	if len(gp.Remove) == 0 {
+		return filepath.Dir(gp.Keep)
+	}
+	return filepath.Dir(gp.Remove[0])
+}
+
+// regroup rebuilds a group plan from the user's keep toggles; the first kept
+// file becomes the one links point to
+func regroup(gp dedupe.GroupPlan, files []string, keep []bool) dedupe.GroupPlan {
+	out := dedupe.GroupPlan{Group: gp.Group, Reason: gp.Reason}
+	for i, path := range files {
+		switch {
+		case keep[i] && out.Keep == "":
+			out.Keep = path
+		case !keep[i]:
+			out.Remove = append(out.Remove, path)
+		}
+	}
+	if out.Keep != gp.Keep {
+		out.Reason = "chosen in review"
+	}
+	return out
+}
@@ -0,0 +1,71 @@
+package review
+
+import (
+	"dupe-file-checker/pkg/dedupe"
+	"dupe-file-checker/pkg/scanner"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testPlan() dedupe.Plan {
+	group := func(files ...string) dedupe.GroupPlan {
+		return dedupe.GroupPlan{
+			Group:  scanner.DuplicateGroup{Size: 100, Files: files},
+			Keep:   files[0],
+			Remove: files[1:],
+		}
+	}
+	return dedupe.Plan{Groups: []dedupe.GroupPlan{
+		group("/a/1.jpg", "/b/1.jpg", "/c/1.jpg"),
+		group("/a/2.jpg", "/b/2.jpg"),
+		group("/a/3.jpg", "/b/3.jpg"),
+		group("/a/4.jpg", "/c/4.jpg"),
+	}}
+}
+
+func TestReviewQueuesUntilConfirmed(t *testing.T) {
+	// Group 1: keep the /c copy too, delete /b. Group 2: link everything
+	// else in /b. Group 4: skip. Then confirm.
+	keys := "3d L s y"
+	queue, err := New(strings.NewReader(keys), io.Discard).Run(testPlan())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(queue.Delete.Groups) != 1 || len(queue.Link.Groups) != 2 {
+		t.Fatalf("Queue = %+v", queue)
+	}
+	del := queue.Delete.Groups[0]
+	if del.Keep != "/a/1.jpg" || len(del.Remove) != 1 || del.Remove[0] != "/b/1.jpg" {
+		t.Errorf("Delete group = %+v", del)
+	}
+	if queue.Link.Groups[1].Remove[0] != "/b/3.jpg" {
+		t.Errorf("Expected directory rule to cover /b/3.jpg, got %+v", queue.Link.Groups[1])
+	}
+	if queue.Len() != 3 {
+		t.Errorf("Len = %d; want 3", queue.Len())
+	}
+}
+
+func TestReviewDeclined(t *testing.T) {
+	queue, err := New(strings.NewReader("d d d d n"), io.Discard).Run(testPlan())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queue.Len() != 0 {
+		t.Errorf("Declined review must queue nothing, got %+v", queue)
+	}
+}
+
+func TestReviewRequiresKeeper(t *testing.T) {
+	// Un-keeping the only kept copy must not allow a delete
+	var out strings.Builder
+	queue, _ := New(strings.NewReader("1d1dq y"), &out).Run(testPlan())
+	if !strings.Contains(out.String(), "Keep at least one copy") {
+		t.Error("Expected a warning when nothing is kept")
+	}
+	if queue.Len() != 2 || queue.Delete.Groups[0].Keep != "/a/1.jpg" {
+		t.Errorf("Queue = %+v", queue)
+	}
+}
@@ -0,0 +1,71 @@
+package scanner
+
+import (
+	"dupe-file-checker/pkg/archive"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// FindEquivalentArchives groups zip and tar archives whose member listings
+// and member contents match, even when the archive bytes differ
+func (s *Scanner) FindEquivalentArchives(files []FileInfo, opts archive.CompareOptions) []DuplicateGroup {
+	var archives []FileInfo
+	for _, f := range files {
+		if archive.IsArchive(f.Path) {
+			archives = append(archives, f)
+		}
+	}
+
+	// Member count and total size are cheap to read and rule out most pairs
+	type listing struct {
+		count int
+		size  int64
+	}
+	var listings sync.Map
+	listingGroups := s.GroupBy(archives, func(f FileInfo) (string, error) {
+		members, err := archive.List(f.Path)
+		if err != nil {
+			return "", err
+		}
+		var l listing
+		for _, m := range members {
+			l.count++
+			l.size += m.Size
+		}
+		listings.Store(f.Path, l)
+		return fmt.Sprintf("%d-%d", l.count, l.size), nil
+	})
+
+	var candidates []FileInfo
+	for _, group := range listingGroups {
+		candidates = append(candidates, group...)
+	}
+
+	var fingerprints sync.Map
+	matches := s.GroupBy(candidates, func(f FileInfo) (string, error) {
+		fp, err := archive.Fingerprint(f.Path, opts)
+		if err != nil {
+			return "", err
+		}
+		fingerprints.Store(f.Path, fp)
+		return strconv.FormatUint(fp, 16), nil
+	})
+
+	var groups []DuplicateGroup
+	for _, members := range matches {
+		fp, _ := fingerprints.Load(members[0].Path)
+		l, _ := listings.Load(members[0].Path)
+
+		group := NewGroup(MatchArchive, fp.(uint64), members)
+		group.Details = append(group.Details, fmt.Sprintf("%d members, %d bytes uncompressed",
+			l.(listing).count, l.(listing).size))
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
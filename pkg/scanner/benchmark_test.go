@@ -55,6 +55,6 @@ func BenchmarkQuickHashOnly(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		sizeGroups := s.groupBySize(files)
-		s.processQuickHashes(sizeGroups)
+		s.processQuickHashes(sizeGroups, nil)
 	}
 }
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package scanner
+
+import (
+	"os"
+)
+
+// FileID is not available on this platform
+func FileID(info os.FileInfo) (dev, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+// linkCount is not available on this platform
+func linkCount(info os.FileInfo) uint64 {
+	return 0
+}
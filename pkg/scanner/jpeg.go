@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"dupe-file-checker/pkg/hasher"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+func isJPEG(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".jpg" || ext == ".jpeg"
+}
+
+// FindSameJPEGData groups JPEGs whose image data is identical even though
+// their EXIF, XMP or other metadata segments differ
+func (s *Scanner) FindSameJPEGData(files []FileInfo) []DuplicateGroup {
+	var jpegs []FileInfo
+	for _, f := range files {
+		if isJPEG(f.Path) {
+			jpegs = append(jpegs, f)
+		}
+	}
+
+	var computed sync.Map
+	matches := s.GroupBy(jpegs, func(f FileInfo) (string, error) {
+		jh, err := hasher.ComputeJPEGHash(f.Path)
+		if err != nil {
+			return "", err
+		}
+		computed.Store(f.Path, jh)
+		return strconv.FormatUint(jh.Image, 16), nil
+	})
+
+	var groups []DuplicateGroup
+	for _, members := range matches {
+		hashes := make(map[string]hasher.JPEGHash, len(members))
+		for _, f := range members {
+			jh, _ := computed.Load(f.Path)
+			hashes[f.Path] = jh.(hasher.JPEGHash)
+		}
+
+		group := NewGroup(MatchImageData, hashes[members[0].Path].Image, members)
+		group.Details = metadataDifferences(members, hashes)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
+
+// metadataDifferences summarises which metadata segments differ within a group
+func metadataDifferences(members []FileInfo, hashes map[string]hasher.JPEGHash) []string {
+	labels := make(map[string]bool)
+	for _, f := range members {
+		for label := range hashes[f.Path].Metadata {
+			labels[label] = true
+		}
+	}
+
+	var sorted []string
+	for label := range labels {
+		sorted = append(sorted, label)
+	}
+	sort.Strings(sorted)
+
+	var details []string
+	for _, label := range sorted {
+		present := 0
+		variants := make(map[uint64]bool)
+		for _, f := range members {
+			if h, ok := hashes[f.Path].Metadata[label]; ok {
+				present++
+				variants[h] = true
+			}
+		}
+
+		switch {
+		case present < len(members):
+			details = append(details, fmt.Sprintf("%s present in %d of %d files", label, present, len(members)))
+		case len(variants) > 1:
+			details = append(details, fmt.Sprintf("%s differs (%d variants)", label, len(variants)))
+		}
+	}
+
+	if len(details) == 0 {
+		details = append(details, "metadata identical")
+	}
+	return details
+}
@@ -0,0 +1,99 @@
+package scanner
+
+import (
+	"dupe-file-checker/pkg/hasher"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// minPartialSize is the smallest file considered as a truncated copy. Below
+// it almost any short file would be the "prefix" of something.
+const minPartialSize = 8 * 1024
+
+// FindPartialCopies finds files whose whole content is the start of a larger
+// file, such as interrupted downloads or copies. Each group holds the complete
+// file first followed by its incomplete copies.
+func (s *Scanner) FindPartialCopies(files []FileInfo) []DuplicateGroup {
+	var candidates []FileInfo
+	for _, f := range files {
+		if f.Size >= minPartialSize {
+			candidates = append(candidates, f)
+		}
+	}
+
+	// Truncated copies share their leading bytes with the complete file
+	heads := s.GroupBy(candidates, func(f FileInfo) (string, error) {
+		h, err := hasher.ComputePrefixHash(f.Path, minPartialSize)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(h, 16), nil
+	})
+
+	partialsOf := make(map[string][]FileInfo)
+	complete := make(map[string]FileInfo)
+	for _, group := range heads {
+		// Stable, so equal sizes keep GroupBy's path order
+		sort.SliceStable(group, func(i, j int) bool {
+			return group[i].Size > group[j].Size
+		})
+
+		// The largest file has nothing larger to be a prefix of
+		for i, partial := range group[1:] {
+			// Compare the partial copy's last bytes with the same range of
+			// each larger file before hashing whole prefixes
+			offset := partial.Size - minPartialSize
+			tail, err := hasher.ComputeRangeHash(partial.Path, offset, minPartialSize)
+			if err != nil {
+				continue
+			}
+
+			var fullHash uint64
+			hashed := false
+
+			// Prefer the largest file the partial copy is a prefix of
+			for _, larger := range group[:i+1] {
+				if larger.Size == partial.Size {
+					continue
+				}
+				sample, err := hasher.ComputeRangeHash(larger.Path, offset, minPartialSize)
+				if err != nil || sample != tail {
+					continue
+				}
+				if !hashed {
+					if fullHash, err = hasher.ComputeFullHash(partial.Path); err != nil {
+						break
+					}
+					hashed = true
+				}
+				prefix, err := hasher.ComputePrefixHash(larger.Path, partial.Size)
+				if err != nil || prefix != fullHash {
+					continue
+				}
+				partialsOf[larger.Path] = append(partialsOf[larger.Path], partial)
+				complete[larger.Path] = larger
+				break
+			}
+		}
+	}
+
+	var groups []DuplicateGroup
+	for path, partials := range partialsOf {
+		full := complete[path]
+		hash, _ := hasher.ComputeFullHash(full.Path)
+
+		group := NewGroup(MatchPrefix, hash, append([]FileInfo{full}, partials...))
+		for _, p := range partials {
+			group.Details = append(group.Details, fmt.Sprintf("%s is %.1f%% of %s",
+				filepath.Base(p.Path), 100*float64(p.Size)/float64(full.Size), filepath.Base(full.Path)))
+		}
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
@@ -1,26 +1,77 @@
 package scanner
 
 import (
+	"dupe-file-checker/pkg/archive"
 	"dupe-file-checker/pkg/hasher"
 	"fmt"
+	"io"
+	"os"
 	"runtime"
+	"sort"
 	"sync"
 	"time"
 )
 
+// MatchKind says what two files in a group have in common
+type MatchKind string
+
+const (
+	// MatchExact groups byte-identical files
+	MatchExact MatchKind = "exact"
+	// MatchImageData groups JPEGs with identical image data but different metadata
+	MatchImageData MatchKind = "same image, different metadata"
+	// MatchPixels groups images that decode to identical pixels
+	MatchPixels MatchKind = "same pixels, different encoding"
+	// MatchAudio groups audio files whose frames are identical but whose tags differ
+	MatchAudio MatchKind = "same audio, different tags"
+	// MatchText groups text files that are equal once normalised
+	MatchText MatchKind = "equivalent text"
+	// MatchArchive groups archives whose members are identical even though
+	// the archive files themselves differ
+	MatchArchive MatchKind = "equivalent archive contents"
+	// MatchPrefix groups a complete file with truncated copies of it; the
+	// complete file comes first
+	MatchPrefix MatchKind = "incomplete copy of"
+)
+
 type DuplicateGroup struct {
 	Hash  uint64
 	Files []string
 	Size  int64
+	Kind  MatchKind
+
+	// Infos holds the scan-time metadata of each file, in the same order as Files
+	Infos []FileInfo
+
+	// Details holds kind-specific notes, such as which metadata differs
+	Details []string
+}
+
+// NewGroup builds a duplicate group of the given kind; Size is that of the
+// first file
+func NewGroup(kind MatchKind, hash uint64, files []FileInfo) DuplicateGroup {
+	group := DuplicateGroup{Hash: hash, Kind: kind}
+	for _, f := range files {
+		group.Files = append(group.Files, f.Path)
+	}
+	group.Infos = append(group.Infos, files...)
+	if len(files) > 0 {
+		group.Size = files[0].Size
+	}
+	return group
 }
 
 type Scanner struct {
 	workers int
+
+	// Progress receives the file count and time estimate; nil silences it
+	Progress io.Writer
 }
 
 func New() *Scanner {
 	return &Scanner{
-		workers: runtime.NumCPU(),
+		workers:  runtime.NumCPU(),
+		Progress: os.Stdout,
 	}
 }
 
@@ -50,6 +101,12 @@ func (s *Scanner) Scan(root string, onlyImages bool) ([]DuplicateGroup, error) {
 		return nil, err
 	}
 
+	return s.ScanFiles(files, onlyImages), nil
+}
+
+// ScanFiles runs the size, quick hash and full hash stages over an already
+// walked file list
+func (s *Scanner) ScanFiles(files []FileInfo, onlyImages bool) []DuplicateGroup {
 	// Display the total count of included files and estimated time before starting scan
 	fileType := "files"
 	if onlyImages {
@@ -66,13 +123,14 @@ func (s *Scanner) Scan(root string, onlyImages bool) ([]DuplicateGroup, error) {
 		timeStr = fmt.Sprintf("%.1fm", estimatedTime.Minutes())
 	}
 
-	fmt.Printf("Found %d %s to scan. Estimated time: %s\nStarting duplicate detection...\n\n", len(files), fileType, timeStr)
+	if s.Progress != nil {
+		fmt.Fprintf(s.Progress, "Found %d %s to scan. Estimated time: %s\nStarting duplicate detection...\n\n", len(files), fileType, timeStr)
+	}
 
 	sizeGroups := s.groupBySize(files)
-	quickGroups := s.processQuickHashes(sizeGroups)
-	duplicates := s.processFullHashes(quickGroups)
-
-	return duplicates, nil
+	members := s.hashArchiveMembers(sizeGroups)
+	quickGroups := s.processQuickHashes(sizeGroups, members)
+	return s.processFullHashes(quickGroups, members)
 }
 
 func (s *Scanner) groupBySize(files []FileInfo) map[int64][]FileInfo {
@@ -90,7 +148,51 @@ func (s *Scanner) groupBySize(files []FileInfo) map[int64][]FileInfo {
 	return filtered
 }
 
-func (s *Scanner) processQuickHashes(sizeGroups map[int64][]FileInfo) map[hasher.QuickHash][]FileInfo {
+// hashArchiveMembers computes both hashes of every archive member left after
+// size grouping, reading each archive once on the worker pool
+func (s *Scanner) hashArchiveMembers(sizeGroups map[int64][]FileInfo) map[string]hasher.MemberHashes {
+	byArchive := make(map[string][]string)
+	for _, group := range sizeGroups {
+		for _, f := range group {
+			if archivePath, member, ok := archive.Split(f.Path); ok {
+				byArchive[archivePath] = append(byArchive[archivePath], member)
+			}
+		}
+	}
+
+	hashes := make(map[string]hasher.MemberHashes)
+	if len(byArchive) == 0 {
+		return hashes
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workChan := make(chan string, 100)
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for archivePath := range workChan {
+				// Members read before a failure are still usable
+				computed, _ := hasher.ComputeMemberHashes(archivePath, byArchive[archivePath])
+				mu.Lock()
+				for member, h := range computed {
+					hashes[archive.VirtualPath(archivePath, member)] = h
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for archivePath := range byArchive {
+		workChan <- archivePath
+	}
+	close(workChan)
+	wg.Wait()
+	return hashes
+}
+
+func (s *Scanner) processQuickHashes(sizeGroups map[int64][]FileInfo, members map[string]hasher.MemberHashes) map[hasher.QuickHash][]FileInfo {
 	type result struct {
 		hash hasher.QuickHash
 		file FileInfo
@@ -105,7 +207,11 @@ func (s *Scanner) processQuickHashes(sizeGroups map[int64][]FileInfo) map[hasher
 		go func() {
 			defer wg.Done()
 			for f := range workChan {
-				qh, err := hasher.ComputeQuickHash(f.Path, f.Size, f.ModTime)
+				if mh, ok := members[f.Path]; ok {
+					resultChan <- result{hash: mh.Quick, file: f}
+					continue
+				}
+				qh, err := hasher.ComputeQuickHash(f.Path, f.Size)
 				if err != nil {
 					continue
 				}
@@ -142,11 +248,10 @@ func (s *Scanner) processQuickHashes(sizeGroups map[int64][]FileInfo) map[hasher
 	return filtered
 }
 
-func (s *Scanner) processFullHashes(quickGroups map[hasher.QuickHash][]FileInfo) []DuplicateGroup {
+func (s *Scanner) processFullHashes(quickGroups map[hasher.QuickHash][]FileInfo, members map[string]hasher.MemberHashes) []DuplicateGroup {
 	type result struct {
 		hash uint64
-		path string
-		size int64
+		file FileInfo
 	}
 
 	workChan := make(chan FileInfo, 100)
@@ -158,11 +263,15 @@ func (s *Scanner) processFullHashes(quickGroups map[hasher.QuickHash][]FileInfo)
 		go func() {
 			defer wg.Done()
 			for f := range workChan {
+				if mh, ok := members[f.Path]; ok {
+					resultChan <- result{hash: mh.Full, file: f}
+					continue
+				}
 				fh, err := hasher.ComputeFullHash(f.Path)
 				if err != nil {
 					continue
 				}
-				resultChan <- result{hash: fh, path: f.Path, size: f.Size}
+				resultChan <- result{hash: fh, file: f}
 			}
 		}()
 	}
@@ -181,23 +290,74 @@ func (s *Scanner) processFullHashes(quickGroups map[hasher.QuickHash][]FileInfo)
 		close(workChan)
 	}()
 
-	fullGroups := make(map[uint64][]string)
-	fileSizes := make(map[uint64]int64)
+	fullGroups := make(map[uint64][]FileInfo)
 	for r := range resultChan {
-		fullGroups[r.hash] = append(fullGroups[r.hash], r.path)
-		fileSizes[r.hash] = r.size // All files with same hash have same size
+		fullGroups[r.hash] = append(fullGroups[r.hash], r.file)
 	}
 
 	var duplicates []DuplicateGroup
-	for hash, paths := range fullGroups {
-		if len(paths) > 1 {
-			duplicates = append(duplicates, DuplicateGroup{
-				Hash:  hash,
-				Files: paths,
-				Size:  fileSizes[hash],
-			})
+	for hash, files := range fullGroups {
+		if len(files) > 1 {
+			// All files with same hash have same size
+			duplicates = append(duplicates, NewGroup(MatchExact, hash, files))
 		}
 	}
 
 	return duplicates
 }
+
+// GroupBy computes key for every file on the worker pool and returns the
+// sets of files sharing a key. Files whose key fails are left out, as are
+// keys held by a single file.
+func (s *Scanner) GroupBy(files []FileInfo, key func(FileInfo) (string, error)) [][]FileInfo {
+	type result struct {
+		key  string
+		file FileInfo
+	}
+
+	workChan := make(chan FileInfo, 100)
+	resultChan := make(chan result, 100)
+
+	var wg sync.WaitGroup
+	for i := 0; i < s.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for f := range workChan {
+				k, err := key(f)
+				if err != nil {
+					continue
+				}
+				resultChan <- result{key: k, file: f}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultChan)
+	}()
+
+	go func() {
+		for _, f := range files {
+			workChan <- f
+		}
+		close(workChan)
+	}()
+
+	groups := make(map[string][]FileInfo)
+	for r := range resultChan {
+		groups[r.key] = append(groups[r.key], r.file)
+	}
+
+	var filtered [][]FileInfo
+	for _, group := range groups {
+		if len(group) > 1 {
+			sort.Slice(group, func(i, j int) bool {
+				return group[i].Path < group[j].Path
+			})
+			filtered = append(filtered, group)
+		}
+	}
+	return filtered
+}
@@ -1,8 +1,19 @@
 package scanner
 
 import (
+	"archive/zip"
+	"bytes"
 	"dupe-file-checker/internal/testutil"
+	"dupe-file-checker/pkg/archive"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestScanNoDuplicates(t *testing.T) {
@@ -144,3 +155,257 @@ func TestScanWithTestFixtures(t *testing.T) {
 		t.Error("Expected to find group of 2 empty files")
 	}
 }
+
+func TestGroupBy(t *testing.T) {
+	files := []FileInfo{
+		{Path: "b.txt", Size: 3},
+		{Path: "a.txt", Size: 3},
+		{Path: "c.txt", Size: 5},
+		{Path: "broken.txt", Size: 3},
+	}
+
+	s := New()
+	groups := s.GroupBy(files, func(f FileInfo) (string, error) {
+		if f.Path == "broken.txt" {
+			return "", os.ErrNotExist
+		}
+		return fmt.Sprint(f.Size), nil
+	})
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	if len(groups[0]) != 2 || groups[0][0].Path != "a.txt" {
+		t.Errorf("Expected sorted group [a.txt b.txt], got %v", groups[0])
+	}
+}
+
+func TestFindSameJPEGData(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	img := image.NewGray(image.Rect(0, 0, 16, 16))
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, img, nil)
+	data := buf.Bytes()
+
+	exif := []byte{0xFF, 0xE1, 0x00, 0x08, 'E', 'x', 'i', 'f', 0, 0}
+	tagged := append(append(append([]byte{}, data[:2]...), exif...), data[2:]...)
+
+	testutil.CreateTestFile(tmpDir+"/original.jpg", string(data))
+	testutil.CreateTestFile(tmpDir+"/tagged.jpg", string(tagged))
+	testutil.CreateTestFile(tmpDir+"/notes.txt", string(data))
+
+	files, _ := Walk(tmpDir, false)
+	groups := New().FindSameJPEGData(files)
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	if groups[0].Kind != MatchImageData {
+		t.Errorf("Kind = %q; want %q", groups[0].Kind, MatchImageData)
+	}
+	if len(groups[0].Files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(groups[0].Files))
+	}
+	if len(groups[0].Details) != 1 || groups[0].Details[0] != "EXIF present in 1 of 2 files" {
+		t.Errorf("Unexpected details %v", groups[0].Details)
+	}
+}
+
+func TestScanMatchesArchiveMembers(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	content := "Report extracted next to its archive"
+	testutil.CreateTestFile(tmpDir+"/docs/report.txt", content)
+
+	f, _ := os.Create(tmpDir + "/backup.zip")
+	zw := zip.NewWriter(f)
+	w, _ := zw.Create("docs/report.txt")
+	w.Write([]byte(content))
+	w, _ = zw.Create("other.txt")
+	w.Write([]byte("Something else entirely"))
+	zw.Close()
+	f.Close()
+
+	files, err := WalkWithOptions(tmpDir, WalkOptions{Archives: true})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(files) != 4 {
+		t.Errorf("Expected 4 files (loose, archive, 2 members), got %d", len(files))
+	}
+
+	duplicates := New().ScanFiles(files, false)
+	if len(duplicates) != 1 {
+		t.Fatalf("Expected 1 duplicate group, got %d", len(duplicates))
+	}
+
+	foundMember := false
+	for _, path := range duplicates[0].Files {
+		if strings.HasSuffix(path, "backup.zip!/docs/report.txt") {
+			foundMember = true
+		}
+	}
+	if !foundMember {
+		t.Errorf("Expected archive member in group, got %v", duplicates[0].Files)
+	}
+}
+
+func TestFindEquivalentArchives(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	writeZip := func(name string, modified time.Time, level uint16, members ...string) {
+		f, _ := os.Create(tmpDir + "/" + name)
+		defer f.Close()
+		zw := zip.NewWriter(f)
+		for _, m := range members {
+			w, _ := zw.CreateHeader(&zip.FileHeader{Name: m, Modified: modified, Method: level})
+			w.Write([]byte("content of " + m))
+		}
+		zw.Close()
+	}
+
+	writeZip("monday.zip", time.Unix(1700000000, 0), zip.Deflate, "a.txt", "b.txt")
+	writeZip("tuesday.zip", time.Unix(1700086400, 0), zip.Store, "b.txt", "a.txt")
+	writeZip("other.zip", time.Unix(1700000000, 0), zip.Deflate, "a.txt", "c.txt")
+
+	files, _ := Walk(tmpDir, false)
+
+	groups := New().FindEquivalentArchives(files, archive.CompareOptions{IgnoreModTimes: true, IgnoreOrder: true})
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	if groups[0].Kind != MatchArchive {
+		t.Errorf("Kind = %q; want %q", groups[0].Kind, MatchArchive)
+	}
+	if len(groups[0].Files) != 2 {
+		t.Errorf("Expected monday and tuesday archives, got %v", groups[0].Files)
+	}
+
+	strict := New().FindEquivalentArchives(files, archive.CompareOptions{})
+	if len(strict) != 0 {
+		t.Errorf("Expected no groups when member order and mtimes count, got %d", len(strict))
+	}
+}
+
+func TestFindPartialCopies(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	movie := strings.Repeat("frame data 0123456789 ", 2000)
+	testutil.CreateTestFile(tmpDir+"/movie.mkv", movie)
+	testutil.CreateTestFile(tmpDir+"/movie.mkv.part", movie[:len(movie)/4])
+	testutil.CreateTestFile(tmpDir+"/copy/movie.mkv", movie[:len(movie)/2])
+	testutil.CreateTestFile(tmpDir+"/diverged.mkv", movie[:len(movie)/2]+"something else")
+	testutil.CreateTestFile(tmpDir+"/tiny.txt", movie[:100])
+
+	files, _ := Walk(tmpDir, false)
+	groups := New().FindPartialCopies(files)
+
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group, got %d", len(groups))
+	}
+	group := groups[0]
+	if group.Kind != MatchPrefix {
+		t.Errorf("Kind = %q; want %q", group.Kind, MatchPrefix)
+	}
+	if !strings.HasSuffix(group.Files[0], "/movie.mkv") || len(group.Files) != 3 {
+		t.Fatalf("Expected movie.mkv with 2 partial copies, got %v", group.Files)
+	}
+	if group.Size != int64(len(movie)) {
+		t.Errorf("Size = %d; want %d", group.Size, len(movie))
+	}
+
+	found := false
+	for _, d := range group.Details {
+		if d == "movie.mkv.part is 25.0% of movie.mkv" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected completion detail for movie.mkv.part, got %v", group.Details)
+	}
+}
+
+func TestWalkRootsListsOverlappingFilesOnce(t *testing.T) {
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(tmpDir+"/a/file.txt", "content")
+	testutil.CreateTestFile(tmpDir+"/b/file.txt", "content")
+
+	files, err := WalkRoots([]string{tmpDir, tmpDir + "/a"}, WalkOptions{})
+	if err != nil {
+		t.Fatalf("WalkRoots failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files, got %d", len(files))
+	}
+}
+
+func TestWalkExclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(tmpDir+"/a.txt", "content")
+	testutil.CreateTestFile(tmpDir+"/.q/files/b.txt", "content")
+
+	files, err := WalkWithOptions(tmpDir, WalkOptions{Exclude: []string{tmpDir + "/.q"}})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected the excluded directory to be skipped, got %v", files)
+	}
+}
+
+func TestWalkSkipsQuarantine(t *testing.T) {
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(tmpDir+"/a.txt", "content")
+	testutil.CreateTestFile(tmpDir+"/stash/journal.jsonl", "")
+	testutil.CreateTestFile(tmpDir+"/stash/files/home/b.txt", "content")
+
+	// Skipped without being excluded, and also when given as the root
+	files, _ := Walk(tmpDir, false)
+	if len(files) != 1 {
+		t.Errorf("Expected the quarantine to be skipped, got %v", files)
+	}
+	files, _ = Walk(tmpDir+"/stash/files/home", false)
+	if len(files) != 0 {
+		t.Errorf("Expected a root inside the quarantine to be skipped, got %v", files)
+	}
+}
+
+func TestWalkRootsListsSymlinkedFilesOnce(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need extra privileges on Windows")
+	}
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(tmpDir+"/real/a.txt", "content")
+	os.Link(tmpDir+"/real/a.txt", tmpDir+"/real/hardlink.txt")
+	if err := os.Symlink(tmpDir+"/real", tmpDir+"/link"); err != nil {
+		t.Skip("symlinks not supported")
+	}
+
+	files, err := WalkRoots([]string{tmpDir + "/real", tmpDir + "/link/"}, WalkOptions{})
+	if err != nil {
+		t.Fatalf("WalkRoots failed: %v", err)
+	}
+	// The hard link is a separate directory entry and stays listed
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files, got %v", files)
+	}
+}
+
+func TestWalkRecordsFileID(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file IDs are not recorded on Windows")
+	}
+
+	tmpDir := t.TempDir()
+	testutil.CreateTestFile(filepath.Join(tmpDir, "a.txt"), "a")
+	testutil.CreateTestFile(filepath.Join(tmpDir, "b.txt"), "b")
+
+	files, err := Walk(tmpDir, false)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Walk = %v, %v", files, err)
+	}
+	if files[0].Inode == 0 || files[0].Inode == files[1].Inode || files[0].Dev != files[1].Dev {
+		t.Errorf("Unexpected file IDs: %+v", files)
+	}
+}
@@ -1,7 +1,9 @@
 package scanner
 
 import (
+	"dupe-file-checker/pkg/archive"
 	"io/fs"
+	"os"
 	"path/filepath"
 	"strings"
 )
@@ -10,6 +12,12 @@ type FileInfo struct {
 	Path    string
 	Size    int64
 	ModTime int64
+	// Dev and Inode identify the file on disk where the platform reports
+	// them; both are zero otherwise and for archive members
+	Dev   uint64
+	Inode uint64
+	// Links is the number of hard links to the file, zero where unknown
+	Links uint64
 }
 
 var imageExtensions = map[string]bool{
@@ -28,8 +36,36 @@ func isImage(path string) bool {
 	return imageExtensions[ext]
 }
 
+// WalkOptions controls which files Walk reports
+type WalkOptions struct {
+	OnlyImages bool
+
+	// Archives also lists the members of zip and tar archives as virtual
+	// files such as backup.zip!/docs/a.pdf
+	Archives bool
+
+	// Exclude lists directories that are not descended into, such as
+	// dedupe's own quarantine
+	Exclude []string
+}
+
+// QuarantineJournal and QuarantineFiles make up dedupe's quarantine layout.
+// A directory holding both is never walked, whatever the options: its files
+// are duplicates already moved out of the way and may be purged at any time.
+const (
+	QuarantineJournal = "journal.jsonl"
+	QuarantineFiles   = "files"
+)
+
 func Walk(root string, onlyImages bool) ([]FileInfo, error) {
+	return WalkWithOptions(root, WalkOptions{OnlyImages: onlyImages})
+}
+
+func WalkWithOptions(root string, opts WalkOptions) ([]FileInfo, error) {
 	var files []FileInfo
+	if inQuarantine(root) {
+		return nil, nil
+	}
 
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
@@ -37,10 +73,17 @@ func Walk(root string, onlyImages bool) ([]FileInfo, error) {
 		}
 
 		if d.IsDir() {
+			if excluded(path, opts.Exclude) || isQuarantine(path) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		if onlyImages && !isImage(path) {
+		if opts.Archives && archive.IsArchive(path) {
+			files = append(files, archiveMembers(path, opts.OnlyImages)...)
+		}
+
+		if opts.OnlyImages && !isImage(path) {
 			return nil
 		}
 
@@ -49,10 +92,14 @@ func Walk(root string, onlyImages bool) ([]FileInfo, error) {
 			return nil
 		}
 
+		dev, ino, _ := FileID(info)
 		files = append(files, FileInfo{
 			Path:    path,
 			Size:    info.Size(),
 			ModTime: info.ModTime().Unix(),
+			Dev:     dev,
+			Inode:   ino,
+			Links:   linkCount(info),
 		})
 
 		return nil
@@ -60,3 +107,112 @@ func Walk(root string, onlyImages bool) ([]FileInfo, error) {
 
 	return files, err
 }
+
+// excluded reports whether dir is one of the excluded directories
+func excluded(dir string, exclude []string) bool {
+	if len(exclude) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	for _, e := range exclude {
+		if e, err := filepath.Abs(e); err == nil && e == abs {
+			return true
+		}
+	}
+	return false
+}
+
+// isQuarantine reports whether dir is a dedupe quarantine directory
+func isQuarantine(dir string) bool {
+	journal, err := os.Lstat(filepath.Join(dir, QuarantineJournal))
+	if err != nil || !journal.Mode().IsRegular() {
+		return false
+	}
+	tree, err := os.Lstat(filepath.Join(dir, QuarantineFiles))
+	return err == nil && tree.IsDir()
+}
+
+// inQuarantine reports whether path lies in a quarantine directory, so a
+// root given inside one is not walked either
+func inQuarantine(path string) bool {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	for {
+		if isQuarantine(dir) {
+			return true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// archiveMembers lists the files inside an archive as virtual files.
+// Unreadable archives are skipped like any other unreadable file.
+func archiveMembers(path string, onlyImages bool) []FileInfo {
+	members, err := archive.List(path)
+	if err != nil {
+		return nil
+	}
+
+	var files []FileInfo
+	for _, m := range members {
+		if onlyImages && !isImage(m.Name) {
+			continue
+		}
+		files = append(files, FileInfo{
+			Path:    archive.VirtualPath(path, m.Name),
+			Size:    m.Size,
+			ModTime: m.ModTime,
+		})
+	}
+	return files
+}
+
+// WalkRoots walks several roots and merges the results. A file reachable
+// more than once, through overlapping roots, symlinked directories or bind
+// mounts, is listed once, so it can never be mistaken for its own duplicate.
+func WalkRoots(roots []string, opts WalkOptions) ([]FileInfo, error) {
+	seen := make(map[string]bool)
+	seenIDs := make(map[[2]uint64]bool)
+	var files []FileInfo
+	for _, root := range roots {
+		rootFiles, err := WalkWithOptions(root, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range rootFiles {
+			key := f.Path
+			if abs, err := filepath.Abs(f.Path); err == nil {
+				key = abs
+			}
+			if real, err := filepath.EvalSymlinks(key); err == nil {
+				key = real
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			// Bind mounts show the same directory entry under another path.
+			// A single-link inode seen twice can only be that; hard links
+			// are distinct entries and stay listed.
+			if f.Links == 1 && f.Inode != 0 {
+				id := [2]uint64{f.Dev, f.Inode}
+				if seenIDs[id] {
+					continue
+				}
+				seenIDs[id] = true
+			}
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
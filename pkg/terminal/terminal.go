@@ -0,0 +1,9 @@
+package terminal
+
+import (
+	"errors"
+)
+
+// ErrUnsupported is returned where raw mode is not implemented; callers
+// fall back to line-buffered input
+var ErrUnsupported = errors.New("raw terminal input is not supported on this platform")
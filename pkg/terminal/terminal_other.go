@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package terminal
+
+// IsTerminal always reports false where raw mode is unavailable
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// Raw is not implemented on this platform
+func Raw(fd int) (func() error, error) {
+	return nil, ErrUnsupported
+}
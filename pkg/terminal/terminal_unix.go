@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package terminal
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// IsTerminal reports whether fd refers to a terminal
+func IsTerminal(fd int) bool {
+	var t syscall.Termios
+	return ioctl(fd, ioctlGetTermios, &t) == nil
+}
+
+// Raw disables line buffering and echo on fd. Signals are left enabled so
+// Ctrl-C still interrupts. The returned function restores the old state.
+func Raw(fd int) (func() error, error) {
+	var old syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		return ioctl(fd, ioctlSetTermios, &old)
+	}, nil
+}
+
+func ioctl(fd int, req uintptr, t *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package textnorm
+
+import (
+	"dupe-file-checker/pkg/hasher"
+	"dupe-file-checker/pkg/scanner"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FindEquivalent groups text files whose normalised content is identical.
+// Groups whose members are all byte-identical are left to the exact scan.
+func FindEquivalent(s *scanner.Scanner, files []scanner.FileInfo, opts Options) []scanner.DuplicateGroup {
+	type result struct {
+		hash   uint64
+		traits Traits
+	}
+
+	// Only the start of each file is read until prefixes collide
+	prefixes := s.GroupBy(files, func(f scanner.FileInfo) (string, error) {
+		isText, err := IsText(f.Path)
+		if err != nil {
+			return "", err
+		}
+		if !isText {
+			return "", fmt.Errorf("%s: not text", f.Path)
+		}
+
+		hash, err := PrefixHash(f.Path, opts)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(hash, 16), nil
+	})
+	var candidates []scanner.FileInfo
+	for _, members := range prefixes {
+		candidates = append(candidates, members...)
+	}
+
+	var results sync.Map
+	matches := s.GroupBy(candidates, func(f scanner.FileInfo) (string, error) {
+		hash, traits, err := Hash(f.Path, opts)
+		if err != nil {
+			return "", err
+		}
+		results.Store(f.Path, result{hash: hash, traits: traits})
+		return strconv.FormatUint(hash, 16), nil
+	})
+
+	var groups []scanner.DuplicateGroup
+	for _, members := range matches {
+		if allIdentical(members) {
+			continue
+		}
+
+		var traits []Traits
+		for _, f := range members {
+			r, _ := results.Load(f.Path)
+			traits = append(traits, r.(result).traits)
+		}
+
+		r, _ := results.Load(members[0].Path)
+		group := scanner.NewGroup(scanner.MatchText, r.(result).hash, members)
+		group.Details = traitDifferences(traits)
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Files[0] < groups[j].Files[0]
+	})
+	return groups
+}
+
+// allIdentical reports whether every file in the group has the same bytes
+func allIdentical(members []scanner.FileInfo) bool {
+	first, err := hasher.ComputeFullHash(members[0].Path)
+	if err != nil {
+		return false
+	}
+	for _, f := range members[1:] {
+		if f.Size != members[0].Size {
+			return false
+		}
+		h, err := hasher.ComputeFullHash(f.Path)
+		if err != nil || h != first {
+			return false
+		}
+	}
+	return true
+}
+
+// traitDifferences describes the encodings and line endings seen in a group
+func traitDifferences(traits []Traits) []string {
+	encodings := make(map[string]bool)
+	endings := make(map[string]bool)
+	for _, t := range traits {
+		encodings[t.Encoding] = true
+		endings[t.LineEndings] = true
+	}
+
+	var details []string
+	if len(encodings) > 1 {
+		details = append(details, "encodings: "+joinKeys(encodings))
+	}
+	if len(endings) > 1 {
+		details = append(details, "line endings: "+joinKeys(endings))
+	}
+	if len(details) == 0 {
+		details = append(details, "whitespace or case differs")
+	}
+	return details
+}
+
+func joinKeys(set map[string]bool) string {
+	var keys []string
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ", ")
+}
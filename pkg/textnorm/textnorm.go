@@ -0,0 +1,323 @@
+package textnorm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Options controls which differences normalisation irons out. Line endings,
+// trailing whitespace and a UTF-8 BOM are always normalised.
+type Options struct {
+	CollapseWhitespace bool
+	FoldCase           bool
+}
+
+// Traits records how a text file was encoded before normalisation
+type Traits struct {
+	Encoding    string
+	LineEndings string
+}
+
+const sniffSize = 8 * 1024
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// IsText sniffs the start of a file and reports whether it looks like text
+func IsText(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	return looksLikeText(buf[:n]), nil
+}
+
+func looksLikeText(data []byte) bool {
+	if bytes.HasPrefix(data, bomUTF16LE) || bytes.HasPrefix(data, bomUTF16BE) {
+		return true
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return false
+	}
+
+	// Allow a few control characters such as form feeds, but not many
+	control := 0
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			control++
+		}
+	}
+	return control*100 <= len(data)
+}
+
+// Normalize decodes data to UTF-8 and applies the normalisation rules
+func Normalize(data []byte, opts Options) (string, Traits) {
+	var b strings.Builder
+	traits, _ := normalizeTo(bytes.NewReader(data), opts, 0, func() io.Writer {
+		b.Reset()
+		return &b
+	})
+	return b.String(), traits
+}
+
+// Hash streams a text file through the normalisation rules and hashes the
+// result, holding no more than a buffer of it in memory
+func Hash(path string, opts Options) (uint64, Traits, error) {
+	return hashFile(path, opts, 0)
+}
+
+// PrefixHash hashes the first sniffSize bytes of a file's normalised
+// content. Equivalent files always share it, so it rules out most pairs
+// before Hash reads whole files. A file that is valid UTF-8 for that long but
+// not further down is hashed as UTF-8 here and as Latin-1 by Hash.
+func PrefixHash(path string, opts Options) (uint64, error) {
+	hash, _, err := hashFile(path, opts, sniffSize)
+	return hash, err
+}
+
+func hashFile(path string, opts Options, limit int) (uint64, Traits, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, Traits{}, err
+	}
+	defer f.Close()
+
+	h := xxhash.New()
+	traits, err := normalizeTo(f, opts, limit, func() io.Writer {
+		h.Reset()
+		return h
+	})
+	if err != nil {
+		return 0, Traits{}, err
+	}
+	return h.Sum64(), traits, nil
+}
+
+var errNotUTF8 = errors.New("not UTF-8")
+
+// normalizeTo decodes r and writes its normalised content to a writer from
+// newWriter, stopping after limit bytes of output when limit is set. Text
+// without a BOM is read as UTF-8 and, if an invalid sequence turns up, read
+// again from the start as Latin-1 into a fresh writer.
+func normalizeTo(r io.ReadSeeker, opts Options, limit int, newWriter func() io.Writer) (Traits, error) {
+	traits, err := normalizeStream(r, opts, limit, newWriter(), false)
+	if err == errNotUTF8 {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Traits{}, err
+		}
+		return normalizeStream(r, opts, limit, newWriter(), true)
+	}
+	return traits, err
+}
+
+func normalizeStream(r io.Reader, opts Options, limit int, w io.Writer, latin1 bool) (Traits, error) {
+	br := bufio.NewReader(r)
+	head, _ := br.Peek(3)
+
+	var next func() (rune, error)
+	traits := Traits{Encoding: "UTF-8"}
+	switch {
+	case latin1:
+		traits.Encoding = "Latin-1"
+		next = func() (rune, error) {
+			b, err := br.ReadByte()
+			return rune(b), err
+		}
+	case bytes.HasPrefix(head, bomUTF8):
+		traits.Encoding = "UTF-8 with BOM"
+		br.Discard(len(bomUTF8))
+		next = func() (rune, error) {
+			c, _, err := br.ReadRune()
+			return c, err
+		}
+	case bytes.HasPrefix(head, bomUTF16LE):
+		traits.Encoding = "UTF-16LE"
+		br.Discard(2)
+		next = utf16Reader(br, binary.LittleEndian)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		traits.Encoding = "UTF-16BE"
+		br.Discard(2)
+		next = utf16Reader(br, binary.BigEndian)
+	default:
+		next = func() (rune, error) {
+			c, size, err := br.ReadRune()
+			if c == utf8.RuneError && size == 1 {
+				return 0, errNotUTF8
+			}
+			return c, err
+		}
+	}
+
+	n := normalizer{opts: opts, w: w, limit: limit}
+	var crlf, cr, lf int
+	prevCR := false
+	for !n.done {
+		c, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Traits{}, err
+		}
+
+		switch {
+		case c == '\r':
+			cr++
+			n.newline()
+		case c == '\n' && prevCR:
+			crlf++
+			cr--
+		case c == '\n':
+			lf++
+			n.newline()
+		default:
+			n.rune(c)
+		}
+		prevCR = c == '\r'
+		if n.err != nil {
+			return Traits{}, n.err
+		}
+	}
+	if err := n.flush(); err != nil {
+		return Traits{}, err
+	}
+
+	traits.LineEndings = lineEndings(crlf, cr, lf)
+	return traits, nil
+}
+
+// utf16Reader decodes UTF-16 code units, replacing unpaired surrogates with
+// U+FFFD. A trailing odd byte is ignored.
+func utf16Reader(br *bufio.Reader, order binary.ByteOrder) func() (rune, error) {
+	buf := make([]byte, 2)
+	var pending rune = -1
+	unit := func() (rune, error) {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return 0, io.EOF
+		}
+		return rune(order.Uint16(buf)), nil
+	}
+	return func() (rune, error) {
+		c := pending
+		pending = -1
+		if c < 0 {
+			var err error
+			if c, err = unit(); err != nil {
+				return 0, err
+			}
+		}
+		if !utf16.IsSurrogate(c) {
+			return c, nil
+		}
+		low, err := unit()
+		if err != nil {
+			return unicode.ReplacementChar, nil
+		}
+		if d := utf16.DecodeRune(c, low); d != unicode.ReplacementChar {
+			return d, nil
+		}
+		pending = low
+		return unicode.ReplacementChar, nil
+	}
+}
+
+const flushSize = 32 * 1024
+
+// normalizer applies the normalisation rules to a stream of runes. Whitespace
+// and line breaks are held back until more content follows them, so trailing
+// whitespace and trailing blank lines never reach the writer.
+type normalizer struct {
+	opts     Options
+	w        io.Writer
+	limit    int
+	out      []byte
+	space    []byte
+	newlines int
+	started  bool
+	written  int
+	done     bool
+	err      error
+}
+
+func (n *normalizer) newline() {
+	n.space = n.space[:0]
+	n.started = false
+	n.newlines++
+}
+
+func (n *normalizer) rune(c rune) {
+	if unicode.IsSpace(c) {
+		switch {
+		case !n.opts.CollapseWhitespace:
+			n.space = utf8.AppendRune(n.space, c)
+		case n.started && len(n.space) == 0:
+			n.space = append(n.space, ' ')
+		}
+		return
+	}
+
+	for ; n.newlines > 0; n.newlines-- {
+		n.out = append(n.out, '\n')
+	}
+	n.out = append(n.out, n.space...)
+	n.space = n.space[:0]
+	if n.opts.FoldCase {
+		c = unicode.ToLower(c)
+	}
+	n.out = utf8.AppendRune(n.out, c)
+	n.started = true
+
+	if len(n.out) >= flushSize || (n.limit > 0 && n.written+len(n.out) >= n.limit) {
+		n.err = n.flush()
+	}
+}
+
+func (n *normalizer) flush() error {
+	out := n.out
+	if n.limit > 0 && n.written+len(out) >= n.limit {
+		out = out[:n.limit-n.written]
+		n.done = true
+	}
+	n.out = n.out[:0]
+	n.written += len(out)
+	_, err := n.w.Write(out)
+	return err
+}
+
+// lineEndings names the line ending style from the counts of each kind
+func lineEndings(crlf, cr, lf int) string {
+	var styles []string
+	if crlf > 0 {
+		styles = append(styles, "CRLF")
+	}
+	if lf > 0 {
+		styles = append(styles, "LF")
+	}
+	if cr > 0 {
+		styles = append(styles, "CR")
+	}
+	if len(styles) == 0 {
+		return "none"
+	}
+	return strings.Join(styles, "+")
+}
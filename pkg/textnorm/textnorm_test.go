@@ -0,0 +1,143 @@
+package textnorm
+
+import (
+	"dupe-file-checker/pkg/scanner"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		opts  Options
+		equal bool
+	}{
+		{"line endings", "a,b\r\nc,d\r\n", "a,b\nc,d\n", Options{}, true},
+		{"trailing whitespace", "key = 1  \nother\t\n\n", "key = 1\nother", Options{}, true},
+		{"utf-8 bom", "\xEF\xBB\xBFhello", "hello", Options{}, true},
+		{"utf-16le", "\xFF\xFEh\x00i\x00\r\x00\n\x00", "hi\n", Options{}, true},
+		{"latin-1", "caf\xE9", "café", Options{}, true},
+		{"inner whitespace kept", "a  b", "a b", Options{}, false},
+		{"inner whitespace collapsed", "a  \t b", "a b", Options{CollapseWhitespace: true}, true},
+		{"case kept", "Hello", "hello", Options{}, false},
+		{"case folded", "Hello", "hello", Options{FoldCase: true}, true},
+	}
+
+	for _, test := range tests {
+		a, _ := Normalize([]byte(test.a), test.opts)
+		b, _ := Normalize([]byte(test.b), test.opts)
+		if (a == b) != test.equal {
+			t.Errorf("%s: Normalize gave %q and %q; equal = %v, want %v", test.name, a, b, a == b, test.equal)
+		}
+	}
+}
+
+func TestNormalizeTraits(t *testing.T) {
+	_, traits := Normalize([]byte("\xEF\xBB\xBFa\r\nb\r\n"), Options{})
+	if traits.Encoding != "UTF-8 with BOM" {
+		t.Errorf("Encoding = %q; want UTF-8 with BOM", traits.Encoding)
+	}
+	if traits.LineEndings != "CRLF" {
+		t.Errorf("LineEndings = %q; want CRLF", traits.LineEndings)
+	}
+}
+
+func TestHashStreams(t *testing.T) {
+	tmpDir := t.TempDir()
+	// Larger than the flush buffer, with a Latin-1 byte far past the start
+	long := strings.Repeat("Line  with trailing space  \r\n\r\n", 4000)
+	tests := []struct {
+		name, content, want string
+		traits              Traits
+	}{
+		{"long.txt", long + "caf\xE9\n\n", strings.Repeat("Line  with trailing space\n\n", 4000) + "café", Traits{"Latin-1", "CRLF+LF"}},
+		{"utf16.txt", "\xFE\xFF\x00h\x00i\xD8\x3D\xDE\x00\x00\n\x00 ", "hi\U0001F600", Traits{"UTF-16BE", "LF"}},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(tmpDir, test.name)
+		os.WriteFile(path, []byte(test.content), 0644)
+
+		hash, traits, err := Hash(path, Options{})
+		if err != nil {
+			t.Fatalf("Hash failed: %v", err)
+		}
+		if hash != xxhash.Sum64String(test.want) {
+			t.Errorf("%s: hash does not match the normalised text", test.name)
+		}
+		if traits != test.traits {
+			t.Errorf("%s: traits = %+v; want %+v", test.name, traits, test.traits)
+		}
+	}
+}
+
+func TestPrefixHash(t *testing.T) {
+	tmpDir := t.TempDir()
+	head := strings.Repeat("x", sniffSize)
+	paths := make(map[string]string)
+	for name, content := range map[string]string{
+		"a.txt": head + "tail one\n",
+		"b.txt": head + "tail two\r\n",
+		"c.txt": "y" + head,
+	} {
+		paths[name] = filepath.Join(tmpDir, name)
+		os.WriteFile(paths[name], []byte(content), 0644)
+	}
+
+	a, _ := PrefixHash(paths["a.txt"], Options{})
+	b, _ := PrefixHash(paths["b.txt"], Options{})
+	c, _ := PrefixHash(paths["c.txt"], Options{})
+	if a != b {
+		t.Error("Expected files differing after the prefix to share a prefix hash")
+	}
+	if a == c {
+		t.Error("Expected files differing at the start to have different prefix hashes")
+	}
+}
+
+func TestLooksLikeText(t *testing.T) {
+	if !looksLikeText([]byte("plain text\n")) {
+		t.Error("Expected plain text to be detected as text")
+	}
+	if looksLikeText([]byte{0x89, 'P', 'N', 'G', 0, 0, 0, 0x0D}) {
+		t.Error("Expected binary data not to be detected as text")
+	}
+}
+
+func TestFindEquivalent(t *testing.T) {
+	tmpDir := t.TempDir()
+	contents := map[string]string{
+		"unix.csv":    "id,name\n1,alice\n",
+		"windows.csv": "id,name\r\n1,alice\r\n",
+		"copy.csv":    "id,name\n1,alice\n",
+		"exact1.txt":  "same bytes\n",
+		"exact2.txt":  "same bytes\n",
+		"binary.dat":  "id,name\x00\n1,alice\n",
+	}
+
+	var files []scanner.FileInfo
+	for name, content := range contents {
+		path := filepath.Join(tmpDir, name)
+		os.WriteFile(path, []byte(content), 0644)
+		files = append(files, scanner.FileInfo{Path: path, Size: int64(len(content))})
+	}
+
+	groups := FindEquivalent(scanner.New(), files, Options{})
+	if len(groups) != 1 {
+		t.Fatalf("Expected 1 group (byte-identical group left out), got %d", len(groups))
+	}
+	if len(groups[0].Files) != 3 {
+		t.Errorf("Expected 3 files, got %v", groups[0].Files)
+	}
+	if groups[0].Kind != scanner.MatchText {
+		t.Errorf("Kind = %q; want %q", groups[0].Kind, scanner.MatchText)
+	}
+	if len(groups[0].Details) != 1 || groups[0].Details[0] != "line endings: CRLF, LF" {
+		t.Errorf("Unexpected details %v", groups[0].Details)
+	}
+}